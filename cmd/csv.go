@@ -0,0 +1,258 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// csvColumns are the columns selected with --columns. Without any, all
+// columns are selected.
+type csvColumns struct {
+	// indexes are the 0-based indexes of the columns given by number
+	indexes map[int]bool
+	// names are the columns given by name, which are looked up in the header
+	// of each input. The header itself is then written as-is.
+	names []string
+}
+
+// parseColumns parses --columns, where each column is either a 1-based
+// number or the name of a column in the header.
+func parseColumns(columns []string) (csvColumns, error) {
+	cols := csvColumns{indexes: map[int]bool{}}
+	for _, column := range columns {
+		column = strings.TrimSpace(column)
+		n, err := strconv.Atoi(column)
+		if err != nil {
+			cols.names = append(cols.names, column)
+			continue
+		}
+		if n < 1 {
+			return csvColumns{}, fmt.Errorf("invalid column %d in --columns, as columns are numbered from 1", n)
+		}
+		cols.indexes[n-1] = true
+	}
+	return cols, nil
+}
+
+// csvField is a field of a CSV record. The offsets are relative to the start
+// of the record. The value of a quoted field is between the quotes, with
+// each pair of double quotes within it meaning a single double quote.
+type csvField struct {
+	valueStart, valueEnd int
+	quoted               bool
+}
+
+// value returns the unquoted value of the field.
+func (f csvField) value(record string) string {
+	value := record[f.valueStart:f.valueEnd]
+	if f.quoted {
+		value = strings.ReplaceAll(value, `""`, `"`)
+	}
+	return value
+}
+
+// inputOffset returns the offset in the record of the byte at offset i of
+// the unquoted value.
+func (f csvField) inputOffset(record string, i int) int {
+	if !f.quoted {
+		return f.valueStart + i
+	}
+	value := record[f.valueStart:f.valueEnd]
+	pos := 0
+	for j := 0; j < i && pos < len(value); j++ {
+		if value[pos] == '"' {
+			pos++
+		}
+		pos++
+	}
+	return f.valueStart + pos
+}
+
+// splitCSV splits a record into its fields, separated by comma. It reports
+// false if the record ends within quotes, and so continues on the next
+// line. Like encoding/csv with LazyQuotes, anything after the closing quote
+// of a field is kept as part of the field, but unlike it, the quoting is
+// kept as it is written.
+func splitCSV(record string, comma byte) ([]csvField, bool) {
+	var fields []csvField
+	i := 0
+	for {
+		if i < len(record) && record[i] == '"' {
+			field := csvField{valueStart: i + 1, quoted: true}
+			j := i + 1
+			for {
+				k := strings.IndexByte(record[j:], '"')
+				if k == -1 {
+					field.valueEnd = len(record)
+					return append(fields, field), false
+				}
+				j += k
+				if j+1 < len(record) && record[j+1] == '"' {
+					j += 2
+					continue
+				}
+				break
+			}
+			field.valueEnd = j
+			fields = append(fields, field)
+			i = j + 1
+			k := strings.IndexByte(record[i:], comma)
+			if k == -1 {
+				return fields, true
+			}
+			i += k + 1
+			continue
+		}
+		field := csvField{valueStart: i, valueEnd: len(record)}
+		if k := strings.IndexByte(record[i:], comma); k != -1 {
+			field.valueEnd = i + k
+		}
+		fields = append(fields, field)
+		if field.valueEnd == len(record) {
+			return fields, true
+		}
+		i = field.valueEnd + 1
+	}
+}
+
+// readCSVRecord reads the next record of br, which spans multiple lines if a
+// quoted field contains newlines. The line ending after the record is
+// returned separately.
+func readCSVRecord(br *bufio.Reader, comma byte) (record string, fields []csvField, ending string, err error) {
+	var sb strings.Builder
+	for {
+		line, err := br.ReadString('\n')
+		sb.WriteString(line)
+		record = sb.String()
+		if err != nil && err != io.EOF {
+			return "", nil, "", err
+		}
+		ending = ""
+		if strings.HasSuffix(record, "\n") {
+			ending = "\n"
+			if strings.HasSuffix(record, "\r\n") {
+				ending = "\r\n"
+			}
+		}
+		var complete bool
+		fields, complete = splitCSV(record[:len(record)-len(ending)], comma)
+		if complete || err == io.EOF {
+			return record[:len(record)-len(ending)], fields, ending, err
+		}
+	}
+}
+
+// needsQuotes reports if a field must be quoted to be read back as value.
+func needsQuotes(value string, comma byte) bool {
+	return strings.IndexByte(value, comma) != -1 || strings.ContainsAny(value, "\"\r\n")
+}
+
+// escapeCSVQuotes doubles each double quote, as they are written in a quoted
+// field. Unquoted fields never contain any, as they then need quotes.
+func escapeCSVQuotes(s string) string {
+	return strings.ReplaceAll(s, `"`, `""`)
+}
+
+// copyCSV encodes or decodes the selected columns of each record of r, and
+// writes the records to out with all other fields, quoting, and line endings
+// kept as they are, except for the line ending of the last record with
+// format.noNewline. A field is only quoted if it was quoted before, or if
+// its new value must be quoted. Selected columns missing from a short or
+// blank record are skipped, so the record is written as it is.
+func copyCSV(fw *fieldWriter, out io.Writer, r io.Reader, name string, comma byte, cols csvColumns, format recordFormat) error {
+	br := bufio.NewReaderSize(r, readBufferSize)
+	selected := cols.indexes
+	readHeader := len(cols.names) > 0
+	// ending is written after the previous record once the next one starts,
+	// so it can be left out after the last record.
+	var ending string
+	var start int64
+	for {
+		record, fields, recordEnding, err := readCSVRecord(br, comma)
+		if record == "" && recordEnding == "" {
+			if err != io.EOF {
+				return err
+			}
+			if format.noNewline {
+				return nil
+			}
+			_, err := io.WriteString(out, ending)
+			return err
+		}
+		recordStart := start
+		start += int64(len(record) + len(recordEnding))
+
+		var sb strings.Builder
+		sb.WriteString(ending)
+		sb.WriteString(format.prefix)
+		ending = recordEnding
+		if readHeader {
+			readHeader = false
+			selected = map[int]bool{}
+			for i := range cols.indexes {
+				selected[i] = true
+			}
+			for _, column := range cols.names {
+				found := false
+				for i, field := range fields {
+					if field.value(record) == column {
+						selected[i] = true
+						found = true
+					}
+				}
+				if !found {
+					return fmt.Errorf("%s: no column named %q in the header", name, column)
+				}
+			}
+			sb.WriteString(record)
+			if _, err := io.WriteString(out, sb.String()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		last := 0
+		for i, field := range fields {
+			if len(selected) > 0 && !selected[i] {
+				continue
+			}
+			value, spans, err := fw.transform(field.value(record), func(j int) int64 {
+				return recordStart + int64(field.inputOffset(record, j))
+			})
+			if err != nil {
+				return err
+			}
+			escaped := fw.highlight(value, spans, escapeCSVQuotes)
+			sb.WriteString(record[last:field.valueStart])
+			if !field.quoted && needsQuotes(value, comma) {
+				escaped = `"` + escaped + `"`
+			}
+			sb.WriteString(escaped)
+			last = field.valueEnd
+		}
+		sb.WriteString(record[last:])
+		fw.endRecord()
+		if _, err := io.WriteString(out, sb.String()); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jilleJr/urlencode/pkg/codec"
+)
+
+func TestCopyCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		comma   byte
+		columns []string
+		format  recordFormat
+		want    string
+	}{
+		{
+			name:    "columns by number",
+			in:      "id,url\n1,a b\n2,c/d\n",
+			columns: []string{"2"},
+			want:    "id,url\n1,a%20b\n2,c%2Fd\n",
+		},
+		{
+			name:    "columns by name",
+			in:      "id,url\n1,a b\n2,c/d\n",
+			columns: []string{"url"},
+			want:    "id,url\n1,a%20b\n2,c%2Fd\n",
+		},
+		{
+			name: "all columns",
+			in:   "a b,c d\n",
+			want: "a%20b,c%20d\n",
+		},
+		{
+			name:    "short and blank records by number",
+			in:      "id,url\n1,a b\n\n2\n3,c d\n",
+			columns: []string{"2"},
+			want:    "id,url\n1,a%20b\n\n2\n3,c%20d\n",
+		},
+		{
+			name:    "short and blank records by name",
+			in:      "id,url\n1,a b\n\n2\n3,c d\n",
+			columns: []string{"url"},
+			want:    "id,url\n1,a%20b\n\n2\n3,c%20d\n",
+		},
+		{
+			name:    "quotes and CRLF kept",
+			in:      "\"a b\",\"c,\"\"d\"\"\"\r\ne f,g\r\n",
+			columns: []string{"2"},
+			want:    "\"a b\",\"c%2C%22d%22\"\r\ne f,g\r\n",
+		},
+		{
+			name:    "multiline field",
+			in:      "1,\"a\nb\"\n2,c\n",
+			columns: []string{"2"},
+			want:    "1,\"a%0Ab\"\n2,c\n",
+		},
+		{
+			name:    "tsv",
+			in:      "a b\tc d\n",
+			comma:   '\t',
+			columns: []string{"1"},
+			want:    "a%20b\tc d\n",
+		},
+		{
+			name:    "no newline",
+			in:      "1,a b\n2,c d\n",
+			columns: []string{"2"},
+			format:  recordFormat{noNewline: true},
+			want:    "1,a%20b\n2,c%20d",
+		},
+		{
+			name:    "prefix",
+			in:      "1,a b\n2,c d",
+			columns: []string{"2"},
+			format:  recordFormat{prefix: "f:"},
+			want:    "f:1,a%20b\nf:2,c%20d",
+		},
+	}
+	for _, tc := range tests {
+		cols, err := parseColumns(tc.columns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		comma := tc.comma
+		if comma == 0 {
+			comma = ','
+		}
+		var out strings.Builder
+		fw := newTestFieldWriter(codec.EncodePathSegment, false, nil)
+		if err := copyCSV(fw, &out, strings.NewReader(tc.in), "test.csv", comma, cols, tc.format); err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if out.String() != tc.want {
+			t.Errorf("%s: want %q, got %q", tc.name, tc.want, out.String())
+		}
+	}
+}
+
+func TestCopyCSVDecodeQuotes(t *testing.T) {
+	var out strings.Builder
+	fw := newTestFieldWriter(codec.EncodePathSegment, true, nil)
+	cols, _ := parseColumns([]string{"2"})
+	in := "1,a%2Cb%22c\n"
+	if err := copyCSV(fw, &out, strings.NewReader(in), "test.csv", ',', cols, recordFormat{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "1,\"a,b\"\"c\"\n"; out.String() != want {
+		t.Errorf("want %q, got %q", want, out.String())
+	}
+}
+
+func TestCopyCSVErrors(t *testing.T) {
+	cols, _ := parseColumns([]string{"url"})
+	fw := newTestFieldWriter(codec.EncodePathSegment, true, nil)
+	err := copyCSV(fw, &strings.Builder{}, strings.NewReader("id,link\n1,a\n"), "test.csv", ',', cols, recordFormat{})
+	if err == nil || !strings.Contains(err.Error(), `no column named "url"`) {
+		t.Errorf("want an error for the missing column, got %v", err)
+	}
+
+	cols, _ = parseColumns([]string{"2"})
+	err = copyCSV(fw, &strings.Builder{}, strings.NewReader("1,a\n2,b%zz\n"), "test.csv", ',', cols, recordFormat{})
+	inputErr, ok := err.(*inputError)
+	if !ok {
+		t.Fatalf("want an *inputError, got %v", err)
+	}
+	if inputErr.offset != 7 {
+		t.Errorf("want offset 7, got %d", inputErr.offset)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	if _, err := parseColumns([]string{"0"}); err == nil {
+		t.Error("want an error for column 0")
+	}
+	cols, err := parseColumns([]string{"1", " url ", "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cols.indexes[0] || !cols.indexes[2] || len(cols.indexes) != 2 {
+		t.Errorf("want indexes 0 and 2, got %v", cols.indexes)
+	}
+	if len(cols.names) != 1 || cols.names[0] != "url" {
+		t.Errorf(`want names ["url"], got %q`, cols.names)
+	}
+}
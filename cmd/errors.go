@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/jilleJr/urlencode/pkg/flagtype"
+)
+
+var (
+	errLocationColor = color.New(color.Bold)
+	errGutterColor   = color.New(color.FgHiBlack)
+	errCaretColor    = color.New(color.FgHiRed, color.Bold)
+)
+
+type jsonError struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Offset  *int64 `json:"offset,omitempty"`
+	Message string `json:"message"`
+	Text    string `json:"text,omitempty"`
+}
+
+func printJSONErr(e jsonError) {
+	enc := json.NewEncoder(stderr)
+	enc.SetEscapeHTML(false)
+	// There's nowhere left to report it if writing to STDERR fails
+	enc.Encode(e)
+}
+
+// printInputErr prints a decoding error together with its location in the
+// input, in the style of compiler errors:
+//
+//	urlencode: err: myfile.txt:3:5: invalid URL escape "%zz"
+//	   3 | foo %zz bar
+//	     |     ^^^
+func printInputErr(filename string, pos position, err *inputError) {
+	if flags.ErrorFormat == flagtype.ErrorFormatJSON {
+		printJSONErr(jsonError{
+			File:    filename,
+			Line:    pos.line,
+			Column:  pos.column,
+			Offset:  &err.offset,
+			Message: err.Error(),
+			Text:    pos.text,
+		})
+		return
+	}
+
+	location := fmt.Sprintf("%s:%d:%d:", filename, pos.line, pos.column)
+	if pos.line == 0 {
+		location = fmt.Sprintf("%s: byte %d:", filename, err.offset+1)
+	}
+	fmt.Fprintln(stderr, errProgramNameColor.Sprint("urlencode:"), errColor.Sprint("err:"),
+		errLocationColor.Sprint(location), err)
+	if !pos.hasText {
+		return
+	}
+
+	lineNum := fmt.Sprint(pos.line)
+	gutter := strings.Repeat(" ", len(lineNum))
+	caretLen := err.err.Len()
+	if max := len(pos.text) - pos.textColumn; caretLen > max && max > 0 {
+		caretLen = max
+	}
+	caretEnd := pos.textColumn + caretLen
+	if caretEnd > len(pos.text) {
+		caretEnd = len(pos.text)
+	}
+	carets := utf8.RuneCountInString(pos.text[pos.textColumn:caretEnd])
+	if carets == 0 {
+		carets = 1
+	}
+	fmt.Fprintln(stderr, errGutterColor.Sprintf(" %s |", lineNum), controlPictures(pos.text))
+	fmt.Fprintln(stderr, errGutterColor.Sprintf(" %s |", gutter),
+		caretIndent(pos.text[:pos.textColumn])+errCaretColor.Sprint(strings.Repeat("^", carets)))
+}
+
+// controlPictures replaces the control characters of text, such as NUL,
+// with their symbols from the Control Pictures block, such as U+2400 "␀",
+// so they aren't written to the terminal as-is. Each is still a single
+// character, so the carets still line up.
+func controlPictures(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t':
+			return r
+		case r < 0x20:
+			return 0x2400 + r
+		case r == 0x7f:
+			return 0x2421
+		}
+		return r
+	}, text)
+}
+
+// caretIndent returns the whitespace that lines up a caret below the end of
+// text, with one space per character, and tabs kept as tabs.
+func caretIndent(text string) string {
+	var sb strings.Builder
+	for _, r := range text {
+		if r == '\t' {
+			sb.WriteByte('\t')
+		} else {
+			sb.WriteByte(' ')
+		}
+	}
+	return sb.String()
+}
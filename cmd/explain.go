@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/jilleJr/urlencode/pkg/codec"
+)
+
+var explainHeaderColor = color.New(color.Bold)
+
+// explainInput writes a table for each record of r, separated by delim, or
+// for all of r if all is set, that tells if and why each character is
+// escaped by c.
+func explainInput(c *codec.Codec, out io.Writer, r io.Reader, all bool, delim byte) error {
+	if all {
+		input, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return writeExplanations(out, c.Explain(string(input)))
+	}
+	return eachRecord(r, delim, func(line string, start int64) error {
+		if start > 0 {
+			fmt.Fprintln(out)
+		}
+		return writeExplanations(out, c.Explain(line))
+	})
+}
+
+// writeExplanations writes the explanations as a table:
+//
+//	CHAR  CODE    OUTPUT  RULE
+//	a     U+0061  a       §2.3 unreserved (alphanumeric)
+//	:     U+003A  %3A     §3.2.1 userinfo: ':' must be escaped, as it separates the username and password
+func writeExplanations(w io.Writer, explanations []codec.Explanation) error {
+	rows := [][]string{{"CHAR", "CODE", "OUTPUT", "RULE"}}
+	for _, e := range explanations {
+		reason := e.Reason
+		if e.Component != "" {
+			reason = fmt.Sprintf("[%s] %s", e.Component, reason)
+		}
+		rows = append(rows, []string{displayChar(e.Char), codePoint(e.Char), e.Output, reason})
+	}
+	widths := make([]int, 3)
+	for _, row := range rows {
+		for i := range widths {
+			if n := utf8.RuneCountInString(row[i]); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	for i, row := range rows {
+		var sb strings.Builder
+		for j, cell := range row {
+			switch {
+			case i == 0:
+				explainHeaderColor.Fprint(&sb, cell)
+			case j == 2 && explanations[i-1].Escaped:
+				escapedColor.Fprint(&sb, cell)
+			default:
+				sb.WriteString(cell)
+			}
+			if j < len(widths) {
+				sb.WriteString(strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell)+2))
+			}
+		}
+		if _, err := fmt.Fprintln(w, sb.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// displayChar returns the character as it can be shown in a table, with
+// control characters and invalid UTF-8 quoted as Go escape sequences.
+func displayChar(char string) string {
+	r, _ := utf8.DecodeRuneInString(char)
+	if r == utf8.RuneError || !strconv.IsPrint(r) || r == ' ' {
+		return strings.Trim(strconv.Quote(char), `"`)
+	}
+	return char
+}
+
+// codePoint returns the Unicode code point of the character, such as
+// "U+00E4", or the byte if it's not valid UTF-8, such as "0xFF".
+func codePoint(char string) string {
+	r, size := utf8.DecodeRuneInString(char)
+	if r == utf8.RuneError && size <= 1 {
+		return fmt.Sprintf("0x%02X", char[0])
+	}
+	return fmt.Sprintf("U+%04X", r)
+}
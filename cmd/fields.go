@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package cmd
+
+import (
+	"errors"
+	"io"
+
+	"github.com/jilleJr/urlencode/pkg/codec"
+)
+
+// fieldWriter encodes or decodes only some parts of each record, such as the
+// selected columns with --csv, while the rest of the record is written
+// as-is.
+type fieldWriter struct {
+	// w must write into rw
+	w    *codec.Writer
+	rw   *recordWriter
+	keep *keepGoing
+	// failed is set when a field of the current record failed with keep
+	failed bool
+}
+
+// transform encodes or decodes the field s, and returns the result together
+// with the changed parts of it. inputOffset turns a byte offset of s into an
+// absolute offset of the input, for error messages.
+//
+// With --keep-going, a field that fails is reported and returned unchanged.
+func (f *fieldWriter) transform(s string, inputOffset func(i int) int64) (string, []codec.Span, error) {
+	f.rw.reset()
+	_, err := io.WriteString(f.w, s)
+	if err == nil {
+		err = f.w.Flush()
+	}
+	if err != nil {
+		var codecErr *codec.Error
+		if !errors.As(err, &codecErr) {
+			return "", nil, err
+		}
+		err = &inputError{offset: inputOffset(codecErr.Offset), err: codecErr}
+		if f.keep == nil {
+			return "", nil, err
+		}
+		f.keep.onError(err)
+		f.failed = true
+		return s, nil, nil
+	}
+	return f.rw.output.String(), f.rw.spans, nil
+}
+
+// highlight returns the result of transform with the changes highlighted,
+// after escaping it to be written within the record.
+func (f *fieldWriter) highlight(s string, spans []codec.Span, escape func(s string) string) string {
+	return highlightEscaped(s, spans, f.rw.hw.color, escape)
+}
+
+// endRecord counts the record for the summary of --keep-going.
+func (f *fieldWriter) endRecord() {
+	if f.keep != nil {
+		f.keep.lines++
+		if f.failed {
+			f.keep.failedLines++
+		}
+	}
+	f.failed = false
+}
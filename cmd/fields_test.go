@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package cmd
+
+import (
+	"github.com/fatih/color"
+	"github.com/jilleJr/urlencode/pkg/codec"
+)
+
+// newTestFieldWriter returns a fieldWriter without colors, which encodes,
+// or decodes if decode is set, using the given encoding.
+func newTestFieldWriter(mode codec.Encoding, decode bool, keep *keepGoing) *fieldWriter {
+	color.NoColor = true
+	c := codec.New(mode, codec.Options{})
+	fw := &fieldWriter{rw: &recordWriter{hw: &highlightWriter{color: escapedColor}}, keep: keep}
+	if decode {
+		fw.w = c.NewDecoder(fw.rw)
+	} else {
+		fw.w = c.NewEncoder(fw.rw)
+	}
+	return fw
+}
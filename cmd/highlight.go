@@ -0,0 +1,129 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/jilleJr/urlencode/pkg/codec"
+)
+
+var escapedColor = color.New(color.FgMagenta)
+var unescapedColor = color.New(color.FgRed)
+var malformedColor = color.New(color.FgYellow, color.Underline)
+var invalidUTF8Color = color.New(color.FgHiWhite, color.BgRed)
+var layerColor = color.New(color.FgHiBlack)
+
+// highlight returns s with the output side of each span colored using c,
+// or using malformedColor for malformed escape sequences and
+// invalidUTF8Color for invalid UTF-8.
+func highlight(s string, spans []codec.Span, c *color.Color) string {
+	if len(spans) == 0 {
+		return s
+	}
+	return highlightEscaped(s, spans, c, func(s string) string { return s })
+}
+
+// highlightEscaped is like highlight, but each part of s is escaped before
+// it's colored, such as to write s within a quoted CSV field.
+func highlightEscaped(s string, spans []codec.Span, c *color.Color, escape func(s string) string) string {
+	var sb strings.Builder
+	last := 0
+	for _, span := range spans {
+		sb.WriteString(escape(s[last:span.OutStart]))
+		part := escape(s[span.OutStart:span.OutEnd])
+		switch span.Kind {
+		case codec.SpanMalformed:
+			malformedColor.Fprint(&sb, part)
+		case codec.SpanInvalidUTF8:
+			invalidUTF8Color.Fprint(&sb, part)
+		default:
+			c.Fprint(&sb, part)
+		}
+		last = span.OutEnd
+	}
+	sb.WriteString(escape(s[last:]))
+	return sb.String()
+}
+
+// highlightWriter colors the changed parts of the output written to it
+// through codec.Writer, and counts the malformed escape sequences and
+// invalid UTF-8.
+// When decoding recursively, it also keeps track of the decoded layers, and
+// writes each of them on their own line if showLayers is set.
+type highlightWriter struct {
+	w          io.Writer
+	color      *color.Color
+	malformed  int
+	invalid    int
+	showLayers bool
+	// maxLayers is the most layers decoded from a single value
+	maxLayers int
+	// values is the number of values decoded recursively
+	values int
+	// stopped is the number of values that were still encoded after
+	// decoding maxDepth layers
+	stopped int
+}
+
+func (w *highlightWriter) Write(p []byte) (int, error) {
+	return w.w.Write(p)
+}
+
+func (w *highlightWriter) WriteSpans(s string, spans []codec.Span) (int, error) {
+	w.count(spans)
+	return io.WriteString(w.w, highlight(s, spans, w.color))
+}
+
+// count adds the malformed escape sequences and invalid UTF-8 in spans to the
+// totals.
+func (w *highlightWriter) count(spans []codec.Span) {
+	for _, span := range spans {
+		switch span.Kind {
+		case codec.SpanMalformed:
+			w.malformed++
+		case codec.SpanInvalidUTF8:
+			w.invalid++
+		}
+	}
+}
+
+func (w *highlightWriter) WriteLayers(layers []string, more bool) error {
+	w.values++
+	if more {
+		w.stopped++
+	}
+	if len(layers) > w.maxLayers {
+		w.maxLayers = len(layers)
+	}
+	if !w.showLayers {
+		return nil
+	}
+	for i := 0; i < len(layers)-1; i++ {
+		layerColor.Fprintf(w.w, "%d: ", i+1)
+		if _, err := fmt.Fprintln(w.w, layers[i]); err != nil {
+			return err
+		}
+	}
+	// The final layer is written by WriteSpans
+	_, err := layerColor.Fprintf(w.w, "%d: ", len(layers))
+	return err
+}
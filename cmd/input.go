@@ -0,0 +1,371 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jilleJr/urlencode/pkg/codec"
+)
+
+const readBufferSize = 64 * 1024
+
+// openInput opens the file named by the first argument, or STDIN if there
+// are no arguments. It also returns the name to use in error messages.
+func openInput(args []string) (io.ReadCloser, string, error) {
+	if len(args) == 0 {
+		return os.Stdin, "<stdin>", nil
+	}
+	file, err := os.Open(args[0])
+	return file, args[0], err
+}
+
+// input is a single source of values: STDIN, a file, or a --value.
+type input struct {
+	name string
+	// value is set for a --value, which is always a single value, as with
+	// --all.
+	value bool
+	open  func() (io.ReadCloser, error)
+}
+
+// inputsOf returns the inputs to read, in order: each of values, and then
+// each file named in args, where "-" is STDIN. STDIN is also read if there
+// are neither.
+func inputsOf(args, values []string) []input {
+	var inputs []input
+	for i, value := range values {
+		value := value
+		inputs = append(inputs, input{
+			name:  fmt.Sprintf("<value %d>", i+1),
+			value: true,
+			open: func() (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader(value)), nil
+			},
+		})
+	}
+	for _, arg := range args {
+		arg := arg
+		if arg == "-" {
+			inputs = append(inputs, stdinInput())
+			continue
+		}
+		inputs = append(inputs, input{
+			name: arg,
+			open: func() (io.ReadCloser, error) {
+				return os.Open(arg)
+			},
+		})
+	}
+	if len(inputs) == 0 {
+		inputs = append(inputs, stdinInput())
+	}
+	return inputs
+}
+
+func stdinInput() input {
+	return input{
+		name: "<stdin>",
+		open: func() (io.ReadCloser, error) {
+			return io.NopCloser(os.Stdin), nil
+		},
+	}
+}
+
+// isTerminal reports if f is a terminal, such as STDIN when nothing is
+// piped into the program.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// eachLine calls fn with each line of r, without the line ending, and the
+// byte offset of the line in r. Unlike copyLines, each line is held in memory
+// as a whole.
+func eachLine(r io.Reader, fn func(line string, start int64) error) error {
+	return eachRecord(r, '\n', fn)
+}
+
+// eachRecord is like eachLine, but splits r into records on the given
+// delimiter. See copyLines for how line endings are handled.
+func eachRecord(r io.Reader, delim byte, fn func(record string, start int64) error) error {
+	br := bufio.NewReaderSize(r, readBufferSize)
+	var start int64
+	for {
+		record, err := br.ReadString(delim)
+		if len(record) > 0 {
+			n := len(record)
+			if err == nil {
+				record = record[:len(record)-1]
+			}
+			record = record[:len(record)-droppedSuffixLen(record, delim, err == nil)]
+			if err := fn(record, start); err != nil {
+				return err
+			}
+			start += int64(n)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// droppedSuffixLen returns the length of the line ending at the end of a
+// record that is not part of the record. With newline as delimiter, that's
+// a \r before the newline, like bufio.ScanLines. With other delimiters,
+// that's a newline at the end of the input, as added by echo.
+func droppedSuffixLen(record string, delim byte, atDelim bool) int {
+	if delim == '\n' {
+		if strings.HasSuffix(record, "\r") {
+			return 1
+		}
+		return 0
+	}
+	switch {
+	case atDelim:
+		return 0
+	case strings.HasSuffix(record, "\r\n"):
+		return 2
+	case strings.HasSuffix(record, "\n"):
+		return 1
+	}
+	return 0
+}
+
+// heldSuffixLen returns the length of the end of data that may turn out to
+// be dropped by droppedSuffixLen, once more of the record is read.
+func heldSuffixLen(data []byte, delim byte) int {
+	switch {
+	case bytes.HasSuffix(data, []byte{'\r'}):
+		return 1
+	case delim == '\n':
+		return 0
+	case bytes.HasSuffix(data, []byte("\r\n")):
+		return 2
+	case bytes.HasSuffix(data, []byte{'\n'}):
+		return 1
+	}
+	return 0
+}
+
+// recordFormat tells how the input is split into records, and what is
+// written after each record.
+type recordFormat struct {
+	delim byte
+	// exact writes the same line ending after each record as it had in the
+	// input, instead of always a newline, and no newline after all input.
+	exact bool
+	// noNewline writes nothing after the last record.
+	noNewline bool
+	// prefix is written before each record, such as the file name with
+	// --with-filename.
+	prefix string
+}
+
+// copyAll encodes or decodes all of r as a single value. With keep set, all
+// of r is held in memory, so it can be written unchanged if it fails.
+func copyAll(w *codec.Writer, out io.Writer, r io.Reader, keep *keepGoing, format recordFormat) error {
+	if _, err := io.WriteString(out, format.prefix); err != nil {
+		return err
+	}
+	if keep == nil {
+		if _, err := io.Copy(w, r); err != nil {
+			return withInputOffset(err, 0)
+		}
+		if err := w.Flush(); err != nil {
+			return withInputOffset(err, 0)
+		}
+	} else {
+		_, err := io.Copy(w, io.TeeReader(r, &keep.raw))
+		if err == nil {
+			err = w.Flush()
+		}
+		keep.lines++
+		if err != nil {
+			var codecErr *codec.Error
+			if !errors.As(err, &codecErr) {
+				return withInputOffset(err, 0)
+			}
+			keep.onError(withInputOffset(err, 0))
+			keep.failedLines++
+			if _, err := io.Copy(&keep.raw, r); err != nil {
+				return err
+			}
+			keep.raw.WriteTo(out)
+		} else {
+			keep.buf.WriteTo(out)
+		}
+		keep.buf.Reset()
+		keep.raw.Reset()
+	}
+	if format.exact || format.noNewline {
+		return nil
+	}
+	_, err := io.WriteString(out, "\n")
+	return err
+}
+
+// keepGoing makes copyLines and copyAll continue with the next line when a line fails
+// to decode, writing the failing line unchanged instead. The output of each
+// line must be written to buf, so it can be discarded if the line fails.
+type keepGoing struct {
+	buf     bytes.Buffer
+	raw     bytes.Buffer
+	failed  bool
+	onError func(err error)
+
+	lines       int
+	failedLines int
+}
+
+// copyLines encodes or decodes each record of r, separated by delim, as a
+// separate value. Records are streamed in chunks, so they can be of any
+// length, unless keep is set.
+//
+// With newline as delimiter, a trailing \r is dropped from each line, like
+// bufio.ScanLines, and each line is written with a newline after it, unless
+// format.exact is set. With other delimiters, or with format.exact, the line
+// ending of each record is written as it was in the input.
+func copyLines(w *codec.Writer, out io.Writer, r io.Reader, keep *keepGoing, format recordFormat) error {
+	delim := format.delim
+	br := bufio.NewReaderSize(r, readBufferSize)
+	inLine := false
+	// ending is written after the previous record once the next one starts,
+	// so it can be left out after the last record.
+	var ending []byte
+	// held is the end of the previous chunk, which is dropped if it turns
+	// out to be a line ending. See heldSuffixLen.
+	var held []byte
+	var consumed, lineStart int64
+
+	write := func(p []byte) error {
+		if keep == nil {
+			_, err := w.Write(p)
+			return withInputOffset(err, lineStart)
+		}
+		keep.raw.Write(p)
+		if keep.failed {
+			return nil
+		}
+		if _, err := w.Write(p); err != nil {
+			keep.failed = true
+			keep.onError(withInputOffset(err, lineStart))
+		}
+		return nil
+	}
+	endLine := func() error {
+		if keep == nil {
+			return withInputOffset(w.Flush(), lineStart)
+		}
+		if !keep.failed {
+			if err := w.Flush(); err != nil {
+				keep.failed = true
+				keep.onError(withInputOffset(err, lineStart))
+			}
+		}
+		keep.lines++
+		if keep.failed {
+			keep.failedLines++
+			keep.raw.WriteTo(out)
+		} else {
+			keep.buf.WriteTo(out)
+		}
+		keep.buf.Reset()
+		keep.raw.Reset()
+		keep.failed = false
+		return nil
+	}
+
+	for {
+		chunk, err := br.ReadSlice(delim)
+		consumed += int64(len(chunk))
+		if len(chunk) > 0 && !inLine {
+			inLine = true
+			if _, err := out.Write(ending); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(out, format.prefix); err != nil {
+				return err
+			}
+			ending = nil
+		}
+		switch err {
+		case nil:
+			chunk = chunk[:len(chunk)-1]
+		case bufio.ErrBufferFull:
+		case io.EOF:
+			if !inLine {
+				if format.noNewline {
+					return nil
+				}
+				_, err := out.Write(ending)
+				return err
+			}
+		default:
+			return err
+		}
+
+		data := chunk
+		if len(held) > 0 {
+			data = append(held, chunk...)
+			held = nil
+		}
+		if err == bufio.ErrBufferFull {
+			n := heldSuffixLen(data, delim)
+			held = append([]byte(nil), data[len(data)-n:]...)
+			if err := write(data[:len(data)-n]); err != nil {
+				return err
+			}
+			continue
+		}
+
+		dropped := droppedSuffixLen(string(data), delim, err == nil)
+		if err := write(data[:len(data)-dropped]); err != nil {
+			return err
+		}
+		if err := endLine(); err != nil {
+			return err
+		}
+		if delim == '\n' && !format.exact {
+			ending = []byte{'\n'}
+		} else {
+			ending = append([]byte(nil), data[len(data)-dropped:]...)
+			if err == nil {
+				ending = append(ending, delim)
+			}
+		}
+		if err == io.EOF {
+			if format.noNewline {
+				return nil
+			}
+			_, err := out.Write(ending)
+			return err
+		}
+		inLine = false
+		lineStart = consumed
+	}
+}
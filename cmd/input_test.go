@@ -0,0 +1,245 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jilleJr/urlencode/pkg/codec"
+)
+
+type copyLinesTest struct {
+	name   string
+	in     string
+	decode bool
+	format recordFormat
+	want   string
+}
+
+// testCopyLines runs copyLines on each test, with records separated by
+// delim.
+func testCopyLines(t *testing.T, delim byte, tests []copyLinesTest) {
+	t.Helper()
+	for _, tc := range tests {
+		tc.format.delim = delim
+		var out strings.Builder
+		c := codec.New(codec.EncodePathSegment, codec.Options{})
+		w := c.NewEncoder(&out)
+		if tc.decode {
+			w = c.NewDecoder(&out)
+		}
+		if err := copyLines(w, &out, strings.NewReader(tc.in), nil, tc.format); err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if out.String() != tc.want {
+			t.Errorf("%s: want %q, got %q", tc.name, shorten(tc.want), shorten(out.String()))
+		}
+	}
+}
+
+// shorten returns s with long runs of the same byte shortened, to keep
+// the failures of long lines readable.
+func shorten(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		j := i
+		for j < len(s) && s[j] == s[i] {
+			j++
+		}
+		if j-i > 10 {
+			sb.WriteString(strings.Repeat(s[i:i+1], 3) + "..." + strings.Repeat(s[i:i+1], 3))
+		} else {
+			sb.WriteString(s[i:j])
+		}
+		i = j
+	}
+	return sb.String()
+}
+
+func TestCopyLines(t *testing.T) {
+	long := strings.Repeat("a", readBufferSize-1)
+	testCopyLines(t, '\n', []copyLinesTest{
+		{name: "lines", in: "a b\nc d\n", want: "a%20b\nc%20d\n"},
+		{name: "no newline at the end", in: "a b\nc d", want: "a%20b\nc%20d\n"},
+		{name: "empty lines", in: "\n\na\n", want: "\n\na\n"},
+		{name: "empty input", in: "", want: ""},
+		{name: "CRLF", in: "a b\r\nc\r\n", want: "a%20b\nc\n"},
+		{name: "CR within the line", in: "a\rb\n", want: "a%0Db\n"},
+		{name: "long line", in: strings.Repeat("a b", readBufferSize) + "\n", want: strings.Repeat("a%20b", readBufferSize) + "\n"},
+		{name: "CRLF across chunks", in: long + "\r\nb\r\n", want: long + "\nb\n"},
+		{name: "CR at the end of a chunk", in: long + "\rb\n", want: long + "%0Db\n"},
+		{name: "CR at the end of the input", in: long + "\r", want: long + "\n"},
+		{name: "escape across chunks", in: long[1:] + "%41\n", decode: true, want: long[1:] + "A\n"},
+		{name: "UTF-8 across chunks", in: long + "ü\n", want: long + "%C3%BC\n"},
+	})
+}
+
+func TestCopyLinesDelimiter(t *testing.T) {
+	long := strings.Repeat("a", readBufferSize-1)
+	testCopyLines(t, 0, []copyLinesTest{
+		{name: "NUL", in: "a b\x00c d\x00", want: "a%20b\x00c%20d\x00"},
+		{name: "NUL without one at the end", in: "a b\x00c d", want: "a%20b\x00c%20d"},
+		{name: "NUL and newline at the end", in: "a b\x00c d\n", want: "a%20b\x00c%20d\n"},
+		{name: "NUL and CRLF at the end", in: "a b\x00c d\r\n", want: "a%20b\x00c%20d\r\n"},
+		{name: "NUL and newline within", in: "a\nb\x00", want: "a%0Ab\x00"},
+		{name: "NUL and CR within", in: "a\rb\x00", want: "a%0Db\x00"},
+		{name: "NUL and CRLF across chunks", in: long + "\r\n", want: long + "\r\n"},
+		{name: "NUL and CRLF within, across chunks", in: long + "\r\nb\x00", want: long + "%0D%0Ab\x00"},
+	})
+	testCopyLines(t, ';', []copyLinesTest{
+		{name: "semicolon", in: "a b;c d;", want: "a%20b;c%20d;"},
+	})
+}
+
+func TestCopyLinesExact(t *testing.T) {
+	exact := recordFormat{exact: true}
+	noNewline := recordFormat{noNewline: true}
+	testCopyLines(t, '\n', []copyLinesTest{
+		{name: "exact CRLF", in: "a b\r\nc\n", format: exact, want: "a%20b\r\nc\n"},
+		{name: "exact without newline at the end", in: "a b\nc", format: exact, want: "a%20b\nc"},
+		{name: "no newline", in: "a b\nc\n", format: noNewline, want: "a%20b\nc"},
+		{name: "no newline and empty line at the end", in: "a\n\n", format: noNewline, want: "a\n"},
+		{name: "no newline and CRLF", in: "a\r\n", format: noNewline, want: "a"},
+	})
+	testCopyLines(t, 0, []copyLinesTest{
+		{name: "no newline after NUL", in: "a\x00b\x00", format: noNewline, want: "a\x00b"},
+	})
+}
+
+func TestCopyAll(t *testing.T) {
+	tests := []struct {
+		in     string
+		format recordFormat
+		want   string
+	}{
+		{"a b\n", recordFormat{}, "a%20b%0A\n"},
+		{"a b\n", recordFormat{exact: true}, "a%20b%0A"},
+		{"a b\n", recordFormat{noNewline: true}, "a%20b%0A"},
+	}
+	for _, tc := range tests {
+		var out strings.Builder
+		w := codec.New(codec.EncodePathSegment, codec.Options{}).NewEncoder(&out)
+		if err := copyAll(w, &out, strings.NewReader(tc.in), nil, tc.format); err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.in, err)
+			continue
+		}
+		if out.String() != tc.want {
+			t.Errorf("%q, %+v: want %q, got %q", tc.in, tc.format, tc.want, out.String())
+		}
+	}
+}
+
+func TestCopyLinesPrefix(t *testing.T) {
+	testCopyLines(t, '\n', []copyLinesTest{
+		{name: "prefix", in: "a b\nc\n", format: recordFormat{prefix: "f:"}, want: "f:a%20b\nf:c\n"},
+		{name: "prefix and empty line", in: "\n", format: recordFormat{prefix: "f:"}, want: "f:\n"},
+		{name: "prefix without input", in: "", format: recordFormat{prefix: "f:"}, want: ""},
+	})
+}
+
+func TestInputsOf(t *testing.T) {
+	inputs := inputsOf([]string{"a.txt", "-"}, []string{"a b"})
+	var names []string
+	for _, in := range inputs {
+		names = append(names, in.name)
+	}
+	want := []string{"<value 1>", "a.txt", "<stdin>"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("want inputs %q, got %q", want, names)
+	}
+	if !inputs[0].value || inputs[1].value || inputs[2].value {
+		t.Errorf("want only the first input to be a value")
+	}
+
+	inputs = inputsOf(nil, nil)
+	if len(inputs) != 1 || inputs[0].name != "<stdin>" {
+		t.Errorf("want STDIN without any arguments, got %+v", inputs)
+	}
+}
+
+func TestCopyLinesKeepGoing(t *testing.T) {
+	long := strings.Repeat("%41", readBufferSize)
+	longDecoded := strings.Repeat("A", readBufferSize)
+	tests := []struct {
+		name   string
+		in     string
+		want   string
+		failed int
+	}{
+		{"ok", "a%41\nb%42\n", "aA\nbB\n", 0},
+		{"failed line kept", "a%41\nb%zz\nc%42\n", "aA\nb%zz\ncB\n", 1},
+		{"incomplete escape at the end of the line", "a%4\nb%42\n", "a%4\nbB\n", 1},
+		{"failed line without newline", "a%41\nb%zz", "aA\nb%zz\n", 1},
+		{"CR dropped from failed line", "b%zz\r\nc\r\n", "b%zz\nc\n", 1},
+		{"failure after the first chunk", long + "%zz\n" + long + "\n", long + "%zz\n" + longDecoded + "\n", 1},
+		{"failure in the first chunk", "%zz" + long + "\n%41\n", "%zz" + long + "\nA\n", 1},
+		{"all lines failed", "%zz\n%\n", "%zz\n%\n", 2},
+	}
+	for _, tc := range tests {
+		var errs []error
+		keep := &keepGoing{onError: func(err error) { errs = append(errs, err) }}
+		w := codec.New(codec.EncodePathSegment, codec.Options{}).NewDecoder(&keep.buf)
+		var out strings.Builder
+		if err := copyLines(w, &out, strings.NewReader(tc.in), keep, recordFormat{delim: '\n'}); err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if out.String() != tc.want {
+			t.Errorf("%s: want %q, got %q", tc.name, shorten(tc.want), shorten(out.String()))
+		}
+		if keep.failedLines != tc.failed || len(errs) != tc.failed {
+			t.Errorf("%s: want %d failed line(s), got %d, with %d error(s)", tc.name, tc.failed, keep.failedLines, len(errs))
+		}
+		if lines := strings.Count(tc.want, "\n"); keep.lines != lines {
+			t.Errorf("%s: want %d line(s), got %d", tc.name, lines, keep.lines)
+		}
+	}
+}
+
+func TestCopyAllKeepGoing(t *testing.T) {
+	for _, in := range []string{"a%41b", "a%zzb"} {
+		keep := &keepGoing{onError: func(err error) {}}
+		w := codec.New(codec.EncodePathSegment, codec.Options{}).NewDecoder(&keep.buf)
+		var out strings.Builder
+		if err := copyAll(w, &out, strings.NewReader(in), keep, recordFormat{exact: true}); err != nil {
+			t.Errorf("%q: unexpected error: %v", in, err)
+			continue
+		}
+		want, err := codec.Unescape(in, codec.EncodePathSegment)
+		if err != nil {
+			want = in
+		}
+		if out.String() != want {
+			t.Errorf("%q: want %q, got %q", in, want, out.String())
+		}
+	}
+}
+
+func TestCopyLinesErrorOffset(t *testing.T) {
+	var out strings.Builder
+	w := codec.New(codec.EncodePathSegment, codec.Options{}).NewDecoder(&out)
+	err := copyLines(w, &out, strings.NewReader("ab\ncd%zz\n"), nil, recordFormat{delim: '\n'})
+	inputErr, ok := err.(*inputError)
+	if !ok {
+		t.Fatalf("want an *inputError, got %v", err)
+	}
+	if inputErr.offset != 5 {
+		t.Errorf("want offset 5, got %d", inputErr.offset)
+	}
+}
@@ -0,0 +1,196 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/jilleJr/urlencode/pkg/codec"
+	"github.com/jilleJr/urlencode/pkg/flagtype"
+)
+
+var promptColor = color.New(color.FgGreen, color.Bold)
+
+const interactiveHelp = `Type a value to encode or decode it, or one of these commands:
+  :mode <encoding>  switch to another encoding, e.g ":mode query"
+  :encode           encode the values
+  :decode           decode the values
+  :explain          toggle a table of why each character is escaped
+  :all-modes        toggle showing the value in all encodings at once
+  :help             show this help
+  :quit             exit, same as Ctrl+D`
+
+// repl is the state of the interactive mode.
+type repl struct {
+	out      io.Writer
+	opts     codec.Options
+	mode     codec.Encoding
+	decode   bool
+	explain  bool
+	allModes bool
+}
+
+// runInteractive reads values and commands from r until it ends, and writes
+// the encoded or decoded values to out right away.
+func runInteractive(r io.Reader, out io.Writer, opts codec.Options) error {
+	repl := &repl{
+		out:    out,
+		opts:   opts,
+		mode:   codec.Encoding(flags.Encode),
+		decode: flags.Decode,
+	}
+	fmt.Fprintln(out, commentColor.Sprint(`Type ":help" for help, and ":quit" or Ctrl+D to exit.`))
+	scanner := bufio.NewScanner(r)
+	for {
+		repl.prompt()
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.HasPrefix(line, ":") {
+			if quit := repl.command(line); quit {
+				return nil
+			}
+			continue
+		}
+		repl.value(line)
+	}
+}
+
+func (r *repl) prompt() {
+	direction := "encode"
+	if r.decode {
+		direction = "decode"
+	}
+	mode := string(r.mode)
+	if r.allModes {
+		mode = "all modes"
+	}
+	promptColor.Fprintf(r.out, "%s, %s> ", mode, direction)
+}
+
+// command runs a command, such as ":mode query", and reports if the
+// interactive mode should end.
+func (r *repl) command(line string) bool {
+	name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case ":mode", ":m":
+		if arg == "" {
+			r.printErr(fmt.Errorf(`missing encoding, e.g ":mode query"`))
+			break
+		}
+		var mode flagtype.Encoding
+		if err := mode.Set(arg); err != nil {
+			r.printErr(err)
+			break
+		}
+		r.mode = codec.Encoding(mode)
+		r.allModes = false
+	case ":encode", ":e":
+		r.decode = false
+	case ":decode", ":d":
+		r.decode = true
+	case ":explain", ":x":
+		r.explain = !r.explain
+		r.printToggle("explain", r.explain)
+	case ":all-modes", ":a":
+		r.allModes = !r.allModes
+		r.printToggle("all modes", r.allModes)
+	case ":help", ":h", ":?":
+		fmt.Fprintln(r.out, interactiveHelp)
+	case ":quit", ":q", ":exit":
+		return true
+	default:
+		r.printErr(fmt.Errorf(`unknown command %q, type ":help" for help`, name))
+	}
+	return false
+}
+
+// value encodes or decodes a value, and prints the result.
+func (r *repl) value(s string) {
+	if r.allModes {
+		width := 0
+		for _, mode := range codec.Encodings {
+			if len(mode) > width {
+				width = len(mode)
+			}
+		}
+		for _, mode := range codec.Encodings {
+			flagValueColor.Fprint(r.out, mode)
+			fmt.Fprint(r.out, strings.Repeat(" ", width-len(mode)+2))
+			r.print(codec.New(mode, r.opts), s)
+		}
+		return
+	}
+	c := codec.New(r.mode, r.opts)
+	r.print(c, s)
+	if r.explain && !r.decode {
+		writeExplanations(r.out, c.Explain(s))
+	}
+}
+
+func (r *repl) print(c *codec.Codec, s string) {
+	if !r.decode {
+		t, spans, err := c.EscapeSpans(s)
+		if err != nil {
+			r.printErr(err)
+			return
+		}
+		fmt.Fprintln(r.out, highlight(t, spans, escapedColor))
+		return
+	}
+	var t string
+	var spans []codec.Span
+	var err error
+	if flags.Recursive {
+		var layers []string
+		layers, spans, _, err = c.UnescapeLayers(s, flags.MaxDepth)
+		t = s
+		if len(layers) > 0 {
+			t = layers[len(layers)-1]
+		}
+	} else {
+		t, spans, err = c.UnescapeSpans(s)
+	}
+	if err != nil {
+		r.printErr(err)
+		return
+	}
+	fmt.Fprintln(r.out, highlight(t, spans, unescapedColor))
+}
+
+func (r *repl) printErr(err error) {
+	if codecErr, ok := err.(*codec.Error); ok {
+		err = fmt.Errorf("column %d: %w", codecErr.Offset+1, err)
+	}
+	fmt.Fprintln(r.out, errColor.Sprint("err:"), err)
+}
+
+func (r *repl) printToggle(name string, on bool) {
+	state := "off"
+	if on {
+		state = "on"
+	}
+	fmt.Fprintln(r.out, commentColor.Sprintf("%s: %s", name, state))
+}
@@ -0,0 +1,491 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// jsonPathElem is an object key or array index within a JSON document.
+type jsonPathElem struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// jsonSelectorElem is a part of a --path, such as ".url", ".*", "[]" or "[0]".
+type jsonSelectorElem struct {
+	key     string
+	index   int
+	anyKey  bool
+	isIndex bool
+	// any matches any object member or array element, as with "[]"
+	any bool
+}
+
+func (e jsonSelectorElem) matches(p jsonPathElem) bool {
+	switch {
+	case e.any:
+		return true
+	case e.anyKey:
+		return !p.isIndex
+	case e.isIndex:
+		return p.isIndex && p.index == e.index
+	}
+	return !p.isIndex && p.key == e.key
+}
+
+// jsonSelector is a parsed --path, which selects the string values to
+// encode or decode, such as ".items[].url" or ".query.*".
+type jsonSelector []jsonSelectorElem
+
+func (s jsonSelector) matches(path []jsonPathElem) bool {
+	if len(s) != len(path) {
+		return false
+	}
+	for i, e := range s {
+		if !e.matches(path[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseJSONSelector parses a --path, made up of these parts after each
+// other, where "." alone selects the whole document:
+//
+//	.key       object member
+//	."key"     object member, with any characters in the key
+//	.*         any object member
+//	[0]        array element
+//	[]         any array element or object member
+func parseJSONSelector(path string) (jsonSelector, error) {
+	if path == "." {
+		return jsonSelector{}, nil
+	}
+	invalid := func(reason string) (jsonSelector, error) {
+		return nil, fmt.Errorf("invalid --path %q: %s", path, reason)
+	}
+	if !strings.HasPrefix(path, ".") && !strings.HasPrefix(path, "[") {
+		return invalid(`must start with "." or "["`)
+	}
+	var sel jsonSelector
+	s := path
+	for s != "" {
+		switch {
+		case strings.HasPrefix(s, ".*"):
+			sel = append(sel, jsonSelectorElem{anyKey: true})
+			s = s[2:]
+		case strings.HasPrefix(s, `."`):
+			end := quotedEnd(s[1:])
+			if end == -1 {
+				return invalid("missing closing quote")
+			}
+			var key string
+			if err := json.Unmarshal([]byte(s[1:1+end]), &key); err != nil {
+				return invalid(err.Error())
+			}
+			sel = append(sel, jsonSelectorElem{key: key})
+			s = s[1+end:]
+		case strings.HasPrefix(s, "."):
+			end := strings.IndexAny(s[1:], ".[")
+			if end == -1 {
+				end = len(s) - 1
+			}
+			if end == 0 {
+				return invalid("missing key after \".\"")
+			}
+			sel = append(sel, jsonSelectorElem{key: s[1 : 1+end]})
+			s = s[1+end:]
+		case strings.HasPrefix(s, "[]"):
+			sel = append(sel, jsonSelectorElem{any: true})
+			s = s[2:]
+		case strings.HasPrefix(s, "["):
+			end := strings.IndexByte(s, ']')
+			if end == -1 {
+				return invalid(`missing closing "]"`)
+			}
+			index, err := strconv.Atoi(s[1:end])
+			if err != nil || index < 0 {
+				return invalid(fmt.Sprintf("invalid array index %q", s[1:end]))
+			}
+			sel = append(sel, jsonSelectorElem{index: index, isIndex: true})
+			s = s[end+1:]
+		default:
+			return invalid(fmt.Sprintf(`unexpected %q, expected "." or "["`, s))
+		}
+	}
+	return sel, nil
+}
+
+// quotedEnd returns the index just after the closing quote of the JSON
+// string that s starts with, or -1 if it has none.
+func quotedEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// jsonSyntaxError is invalid JSON at a byte offset of the input.
+type jsonSyntaxError struct {
+	offset int
+	msg    string
+}
+
+func (e *jsonSyntaxError) Error() string {
+	return e.msg
+}
+
+// jsonScanner reads JSON documents, and writes them to out with only the
+// selected string values encoded or decoded. Everything else, including
+// whitespace and the escaping of unchanged strings, is written as it was.
+type jsonScanner struct {
+	fw        *fieldWriter
+	out       io.Writer
+	selectors []jsonSelector
+	data      string
+	pos       int
+	// last is the end of the input written to out so far
+	last int
+	path []jsonPathElem
+}
+
+// copyJSON encodes or decodes the string values selected by selectors, or
+// all string values if there are none, in each of the JSON documents in r,
+// such as with NDJSON. Object keys are never changed, and neither is the
+// whitespace around them, except for the line ending at the end of r with
+// format.noNewline. All of r is held in memory.
+func copyJSON(fw *fieldWriter, out io.Writer, r io.Reader, name string, selectors []jsonSelector, format recordFormat) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s := &jsonScanner{fw: fw, out: out, selectors: selectors, data: string(data)}
+	err = s.documents(format)
+	var syntaxErr *jsonSyntaxError
+	if errors.As(err, &syntaxErr) {
+		line := 1 + bytes.Count(data[:syntaxErr.offset], []byte{'\n'})
+		column := 1 + utf8.RuneCount(data[bytes.LastIndexByte(data[:syntaxErr.offset], '\n')+1:syntaxErr.offset])
+		return fmt.Errorf("%s:%d:%d: invalid JSON: %s", name, line, column, syntaxErr.msg)
+	}
+	return err
+}
+
+func (s *jsonScanner) documents(format recordFormat) error {
+	for {
+		s.skipSpace()
+		if s.pos == len(s.data) {
+			end := s.pos
+			if format.noNewline && strings.HasSuffix(s.data[s.last:], "\n") {
+				end--
+				if strings.HasSuffix(s.data[s.last:end], "\r") {
+					end--
+				}
+			}
+			return s.flush(end)
+		}
+		if err := s.flush(s.pos); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(s.out, format.prefix); err != nil {
+			return err
+		}
+		if err := s.value(); err != nil {
+			return err
+		}
+		s.fw.endRecord()
+	}
+}
+
+// flush writes the input up until end as-is.
+func (s *jsonScanner) flush(end int) error {
+	_, err := io.WriteString(s.out, s.data[s.last:end])
+	s.last = end
+	return err
+}
+
+func (s *jsonScanner) skipSpace() {
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\r', '\n':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (s *jsonScanner) errorf(format string, args ...interface{}) error {
+	return &jsonSyntaxError{offset: s.pos, msg: fmt.Sprintf(format, args...)}
+}
+
+// expect skips the byte c, and any whitespace after it.
+func (s *jsonScanner) expect(c byte) error {
+	if s.pos == len(s.data) {
+		return s.errorf("expected %q, but got end of input", c)
+	}
+	if s.data[s.pos] != c {
+		return s.errorf("expected %q, but got %q", c, s.data[s.pos])
+	}
+	s.pos++
+	s.skipSpace()
+	return nil
+}
+
+func (s *jsonScanner) value() error {
+	if s.pos == len(s.data) {
+		return s.errorf("expected a value, but got end of input")
+	}
+	switch c := s.data[s.pos]; {
+	case c == '{':
+		return s.object()
+	case c == '[':
+		return s.array()
+	case c == '"':
+		return s.stringValue()
+	case c == '-' || c >= '0' && c <= '9':
+		start := s.pos
+		for s.pos < len(s.data) && strings.IndexByte("+-.0123456789eE", s.data[s.pos]) != -1 {
+			s.pos++
+		}
+		if !json.Valid([]byte(s.data[start:s.pos])) {
+			s.pos = start
+			return s.errorf("invalid number")
+		}
+		return nil
+	}
+	for _, literal := range []string{"true", "false", "null"} {
+		if strings.HasPrefix(s.data[s.pos:], literal) {
+			s.pos += len(literal)
+			return nil
+		}
+	}
+	return s.errorf("expected a value, but got %q", s.data[s.pos])
+}
+
+func (s *jsonScanner) object() error {
+	s.pos++
+	s.skipSpace()
+	if s.pos < len(s.data) && s.data[s.pos] == '}' {
+		s.pos++
+		return nil
+	}
+	for {
+		if s.pos == len(s.data) || s.data[s.pos] != '"' {
+			return s.errorf("expected an object key")
+		}
+		key, _, err := s.str()
+		if err != nil {
+			return err
+		}
+		s.skipSpace()
+		if err := s.expect(':'); err != nil {
+			return err
+		}
+		s.path = append(s.path, jsonPathElem{key: key})
+		err = s.value()
+		s.path = s.path[:len(s.path)-1]
+		if err != nil {
+			return err
+		}
+		s.skipSpace()
+		if s.pos < len(s.data) && s.data[s.pos] == '}' {
+			s.pos++
+			return nil
+		}
+		if err := s.expect(','); err != nil {
+			return s.errorf(`expected "," or "}" after the value of %q`, key)
+		}
+	}
+}
+
+func (s *jsonScanner) array() error {
+	s.pos++
+	s.skipSpace()
+	if s.pos < len(s.data) && s.data[s.pos] == ']' {
+		s.pos++
+		return nil
+	}
+	for i := 0; ; i++ {
+		s.path = append(s.path, jsonPathElem{index: i, isIndex: true})
+		err := s.value()
+		s.path = s.path[:len(s.path)-1]
+		if err != nil {
+			return err
+		}
+		s.skipSpace()
+		if s.pos < len(s.data) && s.data[s.pos] == ']' {
+			s.pos++
+			return nil
+		}
+		if err := s.expect(','); err != nil {
+			return s.errorf(`expected "," or "]"`)
+		}
+	}
+}
+
+func (s *jsonScanner) selected() bool {
+	if len(s.selectors) == 0 {
+		return true
+	}
+	for _, sel := range s.selectors {
+		if sel.matches(s.path) {
+			return true
+		}
+	}
+	return false
+}
+
+// stringValue encodes or decodes the string at the current position, if
+// it's selected.
+func (s *jsonScanner) stringValue() error {
+	start := s.pos
+	value, offsets, err := s.str()
+	if err != nil || !s.selected() {
+		return err
+	}
+	result, spans, err := s.fw.transform(value, func(i int) int64 {
+		if i < len(offsets) {
+			return int64(start + offsets[i])
+		}
+		return int64(s.pos - 1)
+	})
+	if err != nil || result == value {
+		return err
+	}
+	if err := s.flush(start); err != nil {
+		return err
+	}
+	s.last = s.pos
+	_, err = io.WriteString(s.out, `"`+s.fw.highlight(result, spans, escapeJSONString)+`"`)
+	return err
+}
+
+// str reads the string at the current position, and returns its value and
+// the offset of each byte of the value within the quoted string.
+func (s *jsonScanner) str() (string, []int, error) {
+	start := s.pos
+	var sb strings.Builder
+	var offsets []int
+	s.pos++
+	for {
+		if s.pos == len(s.data) {
+			s.pos = start
+			return "", nil, s.errorf("missing closing quote of string")
+		}
+		c := s.data[s.pos]
+		switch {
+		case c == '"':
+			s.pos++
+			return sb.String(), offsets, nil
+		case c < 0x20:
+			return "", nil, s.errorf("invalid control character %q in string", c)
+		case c != '\\':
+			sb.WriteByte(c)
+			offsets = append(offsets, s.pos-start)
+			s.pos++
+			continue
+		}
+		escStart := s.pos
+		if s.pos+1 == len(s.data) {
+			return "", nil, s.errorf("invalid escape in string")
+		}
+		var r rune
+		switch s.data[s.pos+1] {
+		case '"', '\\', '/':
+			r = rune(s.data[s.pos+1])
+		case 'b':
+			r = '\b'
+		case 'f':
+			r = '\f'
+		case 'n':
+			r = '\n'
+		case 'r':
+			r = '\r'
+		case 't':
+			r = '\t'
+		case 'u':
+			var ok bool
+			r, ok = s.hex4(s.pos + 2)
+			if !ok {
+				return "", nil, s.errorf(`invalid \u escape in string`)
+			}
+			s.pos += 4
+			if utf16.IsSurrogate(r) {
+				if r2, ok := s.hex4(s.pos + 4); ok && strings.HasPrefix(s.data[s.pos+2:], `\u`) {
+					r = utf16.DecodeRune(r, r2)
+					s.pos += 6
+				}
+			}
+		default:
+			return "", nil, s.errorf("invalid escape %q in string", s.data[s.pos:s.pos+2])
+		}
+		s.pos += 2
+		n, _ := sb.WriteRune(r)
+		for i := 0; i < n; i++ {
+			offsets = append(offsets, escStart-start)
+		}
+	}
+}
+
+// hex4 parses the 4 hex digits of a \u escape starting at i.
+func (s *jsonScanner) hex4(i int) (rune, bool) {
+	if i+4 > len(s.data) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s.data[i:i+4], 16, 16)
+	return rune(n), err == nil
+}
+
+// escapeJSONString escapes s to be written within a JSON string. Like
+// encoding/json, invalid UTF-8 is replaced with U+FFFD, as JSON strings
+// can't hold it.
+func escapeJSONString(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r < 0x20:
+			fmt.Fprintf(&sb, `\u%04x`, r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
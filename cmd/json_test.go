@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jilleJr/urlencode/pkg/codec"
+)
+
+func TestCopyJSON(t *testing.T) {
+	doc := `{"a":"x y","b":[{"c":"p/q","d":"r s"}],"k y":"v w"}` + "\n"
+	tests := []struct {
+		name   string
+		in     string
+		paths  []string
+		decode bool
+		format recordFormat
+		want   string
+	}{
+		{
+			name: "all strings, but not keys",
+			in:   doc,
+			want: `{"a":"x%20y","b":[{"c":"p%2Fq","d":"r%20s"}],"k y":"v%20w"}` + "\n",
+		},
+		{
+			name:  "path",
+			in:    doc,
+			paths: []string{".a"},
+			want:  `{"a":"x%20y","b":[{"c":"p/q","d":"r s"}],"k y":"v w"}` + "\n",
+		},
+		{
+			name:  "array path",
+			in:    doc,
+			paths: []string{".b[].c"},
+			want:  `{"a":"x y","b":[{"c":"p%2Fq","d":"r s"}],"k y":"v w"}` + "\n",
+		},
+		{
+			name:  "multiple paths",
+			in:    doc,
+			paths: []string{".a", `.b[0].d`},
+			want:  `{"a":"x%20y","b":[{"c":"p/q","d":"r%20s"}],"k y":"v w"}` + "\n",
+		},
+		{
+			name: "NDJSON and whitespace",
+			in:   "{\"a\": \"1 2\"}\r\n\n[ \"3 4\" ]\n",
+			want: "{\"a\": \"1%202\"}\r\n\n[ \"3%204\" ]\n",
+		},
+		{
+			name: "JSON escapes in the input",
+			in:   `{"a":"x\"y é"}`,
+			want: `{"a":"x%22y%20%C3%A9"}`,
+		},
+		{
+			name:   "JSON escapes in the output",
+			in:     `{"a":"x%22y%0A%5C"}`,
+			decode: true,
+			want:   `{"a":"x\"y\n\\"}`,
+		},
+		{
+			name:   "no newline",
+			in:     "{\"a\":\"1 2\"}\n{\"a\":\"3 4\"}\n",
+			format: recordFormat{noNewline: true},
+			want:   "{\"a\":\"1%202\"}\n{\"a\":\"3%204\"}",
+		},
+		{
+			name:   "no newline and CRLF",
+			in:     "{\"a\":\"1 2\"}\r\n",
+			format: recordFormat{noNewline: true},
+			want:   "{\"a\":\"1%202\"}",
+		},
+		{
+			name:   "no newline without one in the input",
+			in:     `"1 2"`,
+			format: recordFormat{noNewline: true},
+			want:   `"1%202"`,
+		},
+	}
+	for _, tc := range tests {
+		var selectors []jsonSelector
+		for _, path := range tc.paths {
+			sel, err := parseJSONSelector(path)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			selectors = append(selectors, sel)
+		}
+		var out strings.Builder
+		fw := newTestFieldWriter(codec.EncodePathSegment, tc.decode, nil)
+		if err := copyJSON(fw, &out, strings.NewReader(tc.in), "test.json", selectors, tc.format); err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if out.String() != tc.want {
+			t.Errorf("%s: want %q, got %q", tc.name, tc.want, out.String())
+		}
+	}
+}
+
+func TestCopyJSONErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{"a": 1, }`, "test.json:1:10: invalid JSON"},
+		{"{}\n[\"a\"", "test.json:2:5: invalid JSON"},
+	}
+	for _, tc := range tests {
+		fw := newTestFieldWriter(codec.EncodePathSegment, false, nil)
+		err := copyJSON(fw, &strings.Builder{}, strings.NewReader(tc.in), "test.json", nil, recordFormat{})
+		if err == nil || !strings.HasPrefix(err.Error(), tc.want) {
+			t.Errorf("%q: want error %q, got %v", tc.in, tc.want, err)
+		}
+	}
+}
+
+func TestCopyJSONKeepGoing(t *testing.T) {
+	keep := &keepGoing{onError: func(err error) {}}
+	fw := newTestFieldWriter(codec.EncodePathSegment, true, keep)
+	var out strings.Builder
+	in := "{\"a\":\"%zz\",\"b\":\"%41\"}\n{\"a\":\"%42\"}\n"
+	if err := copyJSON(fw, &out, strings.NewReader(in), "test.json", nil, recordFormat{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"a\":\"%zz\",\"b\":\"A\"}\n{\"a\":\"B\"}\n"; out.String() != want {
+		t.Errorf("want %q, got %q", want, out.String())
+	}
+	if keep.lines != 2 || keep.failedLines != 1 {
+		t.Errorf("want 1 of 2 documents failed, got %d of %d", keep.failedLines, keep.lines)
+	}
+}
+
+func TestParseJSONSelectorErrors(t *testing.T) {
+	for _, path := range []string{"a", ".a[", ".a[x]", ""} {
+		if _, err := parseJSONSelector(path); err == nil {
+			t.Errorf("parseJSONSelector(%q): want an error", path)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package cmd
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// copyMatches encodes or decodes only the parts of each record of r that
+// match re, or its capture group if group is above 0, and writes the records
+// to out with everything else, including the line endings, kept as-is. The
+// line ending of the last record is left out with format.noNewline.
+func copyMatches(fw *fieldWriter, out io.Writer, r io.Reader, re *regexp.Regexp, group int, format recordFormat) error {
+	delim := format.delim
+	br := bufio.NewReaderSize(r, readBufferSize)
+	// ending is written after the previous record once the next one starts,
+	// so it can be left out after the last record.
+	var ending string
+	var start int64
+	for {
+		record, err := br.ReadString(delim)
+		if record == "" {
+			if err != io.EOF {
+				return err
+			}
+			if format.noNewline {
+				return nil
+			}
+			_, err := io.WriteString(out, ending)
+			return err
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		recordStart := start
+		start += int64(len(record))
+		recordEnding := ""
+		if strings.HasSuffix(record, string(delim)) {
+			recordEnding = string(delim)
+			if delim == '\n' && strings.HasSuffix(record, "\r\n") {
+				recordEnding = "\r\n"
+			}
+			record = record[:len(record)-len(recordEnding)]
+		}
+
+		var sb strings.Builder
+		sb.WriteString(ending)
+		sb.WriteString(format.prefix)
+		ending = recordEnding
+		last := 0
+		for _, match := range re.FindAllStringSubmatchIndex(record, -1) {
+			matchStart, matchEnd := match[2*group], match[2*group+1]
+			// The group may not be part of this match, such as with "a(b)?"
+			if matchStart < 0 {
+				continue
+			}
+			value, spans, err := fw.transform(record[matchStart:matchEnd], func(i int) int64 {
+				return recordStart + int64(matchStart+i)
+			})
+			if err != nil {
+				return err
+			}
+			sb.WriteString(record[last:matchStart])
+			sb.WriteString(fw.highlight(value, spans, func(s string) string { return s }))
+			last = matchEnd
+		}
+		sb.WriteString(record[last:])
+		fw.endRecord()
+		if _, err := io.WriteString(out, sb.String()); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package cmd
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jilleJr/urlencode/pkg/codec"
+)
+
+func TestCopyMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		re     string
+		group  int
+		format recordFormat
+		want   string
+	}{
+		{
+			name: "whole match",
+			in:   "x?q=a b&r=c d\n",
+			re:   `q=[^&]*`,
+			want: "x?q%3Da+b&r=c d\n",
+		},
+		{
+			name:  "group",
+			in:    "x?q=a b&r=c d\n",
+			re:    `q=([^&]*)`,
+			group: 1,
+			want:  "x?q=a+b&r=c d\n",
+		},
+		{
+			name:  "multiple matches per line",
+			in:    "q=a b&q=c d\nq=e f\n",
+			re:    `q=([^&]*)`,
+			group: 1,
+			want:  "q=a+b&q=c+d\nq=e+f\n",
+		},
+		{
+			name:  "unmatched optional group",
+			in:    "q=&r\n",
+			re:    `q=(x)?`,
+			group: 1,
+			want:  "q=&r\n",
+		},
+		{
+			name: "CRLF kept",
+			in:   "a b\r\nc d\r\n",
+			re:   `[a-z] [a-z]`,
+			want: "a+b\r\nc+d\r\n",
+		},
+		{
+			name:   "no newline",
+			in:     "a b\nc d\n",
+			re:     `[a-z] [a-z]`,
+			format: recordFormat{noNewline: true},
+			want:   "a+b\nc+d",
+		},
+		{
+			name:   "no newline and CRLF",
+			in:     "a b\r\n",
+			re:     `[a-z] [a-z]`,
+			format: recordFormat{noNewline: true},
+			want:   "a+b",
+		},
+		{
+			name:   "prefix",
+			in:     "a b\nc d",
+			re:     `[a-z] [a-z]`,
+			format: recordFormat{prefix: "f:"},
+			want:   "f:a+b\nf:c+d",
+		},
+	}
+	for _, tc := range tests {
+		if tc.format.delim == 0 {
+			tc.format.delim = '\n'
+		}
+		var out strings.Builder
+		fw := newTestFieldWriter(codec.EncodeQueryComponent, false, nil)
+		err := copyMatches(fw, &out, strings.NewReader(tc.in), regexp.MustCompile(tc.re), tc.group, tc.format)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if out.String() != tc.want {
+			t.Errorf("%s: want %q, got %q", tc.name, tc.want, out.String())
+		}
+	}
+}
+
+func TestCopyMatchesErrorOffset(t *testing.T) {
+	fw := newTestFieldWriter(codec.EncodeQueryComponent, true, nil)
+	err := copyMatches(fw, &strings.Builder{}, strings.NewReader("q=a\nx&q=%zz\n"), regexp.MustCompile(`q=([^&]*)`), 1, recordFormat{delim: '\n'})
+	inputErr, ok := err.(*inputError)
+	if !ok {
+		t.Fatalf("want an *inputError, got %v", err)
+	}
+	if inputErr.offset != 8 {
+		t.Errorf("want offset 8, got %d", inputErr.offset)
+	}
+}
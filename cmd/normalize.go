@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jilleJr/urlencode/pkg/codec"
+	"github.com/spf13/cobra"
+)
+
+var normalizeCmd = &cobra.Command{
+	Use:   "normalize [file]",
+	Short: "Normalizes URLs into their canonical form",
+	Long: `Normalizes each line of the input as a URL into its canonical form,
+following RFC 3986 section 6, and prints it to STDOUT.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		reader, filename, err := openInput(args)
+		if err != nil {
+			printErr(err)
+			os.Exit(3)
+		}
+		defer reader.Close()
+
+		out := bufio.NewWriter(stdout)
+		lineNum := 0
+		err = eachLine(reader, func(line string, lineStart int64) error {
+			lineNum++
+			normalized, err := codec.Normalize(line)
+			var codecErr *codec.Error
+			if errors.As(err, &codecErr) {
+				out.Flush()
+				printInputErr(filename, position{
+					line:       lineNum,
+					column:     codecErr.Offset + 1,
+					text:       line,
+					textColumn: codecErr.Offset,
+				}, &inputError{offset: lineStart + int64(codecErr.Offset), err: codecErr})
+				os.Exit(2)
+			}
+			_, err = fmt.Fprintln(out, normalized)
+			return err
+		})
+		out.Flush()
+		if err != nil {
+			printErr(err)
+			os.Exit(2)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(normalizeCmd)
+}
@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// atomicFile is written as a temporary file next to path, which then
+// replaces path on Commit, so that path is never left half-written.
+type atomicFile struct {
+	*os.File
+	path string
+}
+
+// createAtomic creates a temporary file to replace path with. Unlike
+// os.CreateTemp, it gets the permissions of path if it already exists, or
+// else the same permissions as a file created by os.Create.
+func createAtomic(path string) (*atomicFile, error) {
+	perm := os.FileMode(0666)
+	info, statErr := os.Stat(path)
+	if statErr == nil {
+		perm = info.Mode().Perm()
+	}
+	dir, base := filepath.Split(path)
+	for i := 0; ; i++ {
+		name := filepath.Join(dir, fmt.Sprintf(".%s.%d-%d.tmp", base, os.Getpid(), i))
+		file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) && i < 100 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		f := &atomicFile{File: file, path: path}
+		// The umask may have removed some of the permissions of path
+		if statErr == nil {
+			if err := file.Chmod(perm); err != nil {
+				f.Discard()
+				return nil, err
+			}
+		}
+		return f, nil
+	}
+}
+
+// Commit syncs and closes the temporary file, and renames it to path. If
+// backupSuffix is set, the old file at path is first renamed to
+// path+backupSuffix, like with sed -i.
+func (f *atomicFile) Commit(backupSuffix string) error {
+	// Without syncing first, a crash after the rename may leave path empty
+	if err := f.Sync(); err != nil {
+		f.Discard()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if backupSuffix != "" {
+		if err := os.Rename(f.path, f.path+backupSuffix); err != nil {
+			os.Remove(f.Name())
+			return err
+		}
+	}
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		if backupSuffix != "" {
+			os.Rename(f.path+backupSuffix, f.path)
+		}
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// Discard closes and removes the temporary file, leaving path as it was.
+func (f *atomicFile) Discard() {
+	f.Close()
+	os.Remove(f.Name())
+}
@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string, perm os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		t.Fatal(err)
+	}
+	// WriteFile is subject to the umask
+	if err := os.Chmod(path, perm); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestAtomicFileCommit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	writeTestFile(t, path, "old\n", 0600)
+
+	f, err := createAtomic(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("new\n")
+	if got := readTestFile(t, path); got != "old\n" {
+		t.Errorf("want %q before Commit, got %q", "old\n", got)
+	}
+	if err := f.Commit(""); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, path); got != "new\n" {
+		t.Errorf("want %q, got %q", "new\n", got)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("want permissions %v, got %v", os.FileMode(0600), perm)
+	}
+	assertOnlyFiles(t, filepath.Dir(path), "file.txt")
+}
+
+func TestAtomicFileCommitBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	writeTestFile(t, path, "old\n", 0644)
+
+	f, err := createAtomic(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("new\n")
+	if err := f.Commit(".bak"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, path); got != "new\n" {
+		t.Errorf("want %q, got %q", "new\n", got)
+	}
+	if got := readTestFile(t, path+".bak"); got != "old\n" {
+		t.Errorf("want backup %q, got %q", "old\n", got)
+	}
+	assertOnlyFiles(t, filepath.Dir(path), "file.txt", "file.txt.bak")
+}
+
+func TestAtomicFileDiscard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	writeTestFile(t, path, "old\n", 0644)
+
+	f, err := createAtomic(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("new\n")
+	f.Discard()
+	if got := readTestFile(t, path); got != "old\n" {
+		t.Errorf("want %q, got %q", "old\n", got)
+	}
+	assertOnlyFiles(t, filepath.Dir(path), "file.txt")
+}
+
+func TestAtomicFileNewPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	f, err := createAtomic(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("new\n")
+	if err := f.Commit(""); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, path); got != "new\n" {
+		t.Errorf("want %q, got %q", "new\n", got)
+	}
+}
+
+// assertOnlyFiles fails the test if dir has any other files than names,
+// such as a leftover temporary file.
+func assertOnlyFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	if len(got) != len(names) {
+		t.Fatalf("want files %q, got %q", names, got)
+	}
+	for i := range names {
+		if got[i] != names[i] {
+			t.Fatalf("want files %q, got %q", names, got)
+		}
+	}
+}
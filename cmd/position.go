@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"unicode/utf8"
+
+	"github.com/jilleJr/urlencode/pkg/codec"
+)
+
+// maxPositionWindow is how much of the most recently read input that
+// positionReader remembers. It must be larger than the read-ahead of any
+// buffering done on top of it.
+const maxPositionWindow = 4 * readBufferSize
+
+// positionReader remembers the most recently read input, so that byte
+// offsets into it can be turned into line and column numbers.
+type positionReader struct {
+	r           io.Reader
+	window      []byte
+	windowStart int64
+	// lines is the number of newlines before windowStart
+	lines int
+	// lineStart is the offset of the line that windowStart is in
+	lineStart int64
+}
+
+type position struct {
+	// line and column are 0 when unknown
+	line   int
+	column int
+	// hasText is set when text is known
+	hasText bool
+	// text is the line, or a part of it if it's too long
+	text string
+	// textColumn is the 0-based index of the position in text
+	textColumn int
+}
+
+func newPositionReader(r io.Reader) *positionReader {
+	return &positionReader{r: r}
+}
+
+func (p *positionReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.window = append(p.window, b[:n]...)
+	if drop := len(p.window) - maxPositionWindow; drop > 0 {
+		dropped := p.window[:drop]
+		if i := bytes.LastIndexByte(dropped, '\n'); i != -1 {
+			p.lines += bytes.Count(dropped, []byte{'\n'})
+			p.lineStart = p.windowStart + int64(i) + 1
+		}
+		p.windowStart += int64(drop)
+		p.window = append(p.window[:0], p.window[drop:]...)
+	}
+	return n, err
+}
+
+// position returns the line and column of the absolute offset, and the text
+// around it. When the offset is older than what's still remembered, there is
+// no text, and unless the offset is still within the same line as what's
+// remembered, the line and column are unknown too.
+func (p *positionReader) position(offset int64) position {
+	i := int(offset - p.windowStart)
+	if i < 0 {
+		if offset < p.lineStart {
+			return position{}
+		}
+		return position{line: p.lines + 1, column: int(offset-p.lineStart) + 1}
+	}
+	if i > len(p.window) {
+		i = len(p.window)
+	}
+	before := p.window[:i]
+	pos := position{
+		line:    p.lines + bytes.Count(before, []byte{'\n'}) + 1,
+		hasText: true,
+	}
+	textStart := bytes.LastIndexByte(before, '\n') + 1
+	if textStart == 0 {
+		pos.column = int(offset-p.lineStart) + 1
+	} else {
+		pos.column = i - textStart + 1
+	}
+	textEnd := bytes.IndexByte(p.window[i:], '\n')
+	if textEnd == -1 {
+		textEnd = len(p.window)
+	} else {
+		textEnd += i
+	}
+	// Only show some context around the position on very long lines.
+	const context = 40
+	if i-textStart > context {
+		textStart = i - context
+	}
+	if textEnd-i > context {
+		textEnd = i + context
+	}
+	// Don't cut characters in half at the edges of the context
+	for textStart < i && !utf8.RuneStart(p.window[textStart]) {
+		textStart++
+	}
+	for textEnd > i && textEnd < len(p.window) && !utf8.RuneStart(p.window[textEnd]) {
+		textEnd--
+	}
+	pos.text = string(bytes.TrimSuffix(p.window[textStart:textEnd], []byte{'\r'}))
+	pos.textColumn = i - textStart
+	return pos
+}
+
+// inputError is a decoding error at an absolute byte offset of the input.
+type inputError struct {
+	offset int64
+	err    *codec.Error
+}
+
+func (e *inputError) Error() string {
+	return e.err.Error()
+}
+
+func (e *inputError) Unwrap() error {
+	return e.err
+}
+
+// withInputOffset turns decoding errors from a value that starts at the
+// given absolute offset of the input into an inputError.
+func withInputOffset(err error, valueStart int64) error {
+	var codecErr *codec.Error
+	if errors.As(err, &codecErr) {
+		return &inputError{offset: valueStart + int64(codecErr.Offset), err: codecErr}
+	}
+	return err
+}
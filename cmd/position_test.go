@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package cmd
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// readPositions reads all of in through a positionReader, and returns it.
+func readPositions(t *testing.T, in string) *positionReader {
+	t.Helper()
+	pr := newPositionReader(strings.NewReader(in))
+	if _, err := io.Copy(io.Discard, pr); err != nil {
+		t.Fatal(err)
+	}
+	return pr
+}
+
+func TestPosition(t *testing.T) {
+	pr := readPositions(t, "abc\ndef\r\nghi")
+	tests := []struct {
+		offset int64
+		want   position
+	}{
+		{0, position{line: 1, column: 1, hasText: true, text: "abc", textColumn: 0}},
+		{5, position{line: 2, column: 2, hasText: true, text: "def", textColumn: 1}},
+		{11, position{line: 3, column: 3, hasText: true, text: "ghi", textColumn: 2}},
+	}
+	for _, tc := range tests {
+		if got := pr.position(tc.offset); got != tc.want {
+			t.Errorf("position(%d): want %+v, got %+v", tc.offset, tc.want, got)
+		}
+	}
+}
+
+func TestPositionContext(t *testing.T) {
+	line := strings.Repeat("a", 100) + "ü" + strings.Repeat("b", 100)
+	pr := readPositions(t, line)
+	got := pr.position(102)
+	if got.line != 1 || got.column != 103 {
+		t.Errorf("want line 1, column 103, got %d:%d", got.line, got.column)
+	}
+	if want := strings.Repeat("a", 38) + "ü" + strings.Repeat("b", 40); got.text != want {
+		t.Errorf("want text %q, got %q", want, got.text)
+	}
+	if got.textColumn != 40 {
+		t.Errorf("want text column 40, got %d", got.textColumn)
+	}
+
+	// The context would start within the ü, which is then left out
+	got = pr.position(141)
+	if want := strings.Repeat("b", 79); got.text != want {
+		t.Errorf("want text %q, got %q", want, got.text)
+	}
+}
+
+func TestPositionForgotten(t *testing.T) {
+	// The start of a long line is no longer remembered
+	pr := readPositions(t, strings.Repeat("a", 2*maxPositionWindow))
+	got := pr.position(0)
+	if want := (position{line: 1, column: 1}); got != want {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+
+	// Neither are the earlier lines
+	pr = readPositions(t, "abc\n"+strings.Repeat("a", 2*maxPositionWindow))
+	got = pr.position(1)
+	if want := (position{}); got != want {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestControlPictures(t *testing.T) {
+	got := controlPictures("a\x00b\tc\x1b[0m\x7f")
+	if want := "a␀b\tc␛[0m␡"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
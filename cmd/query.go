@@ -0,0 +1,273 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jilleJr/urlencode/pkg/codec"
+	"github.com/jilleJr/urlencode/pkg/flagtype"
+	"github.com/spf13/cobra"
+)
+
+var queryFlags = struct {
+	Format flagtype.QueryFormat
+}{
+	Format: flagtype.QueryFormatTable,
+}
+
+var parseQueryCmd = &cobra.Command{
+	Use:   "parse-query [file]",
+	Short: "Decodes query strings into key and value pairs",
+	Long: `Decodes each line of the input as a query string, such as
+"a=1&b=hello+world&a=2", and prints its key and value pairs to STDOUT,
+keeping their order and any repeated keys. Lines with a whole URL
+are decoded from the query part of the URL.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		reader, filename, err := openInput(args)
+		if err != nil {
+			printErr(err)
+			os.Exit(3)
+		}
+		defer reader.Close()
+
+		out := bufio.NewWriter(stdout)
+		lineNum := 0
+		err = eachLine(reader, func(line string, lineStart int64) error {
+			lineNum++
+			query, queryStart := queryOfLine(line)
+			pairs, err := codec.ParseQuery(query)
+			var codecErr *codec.Error
+			if errors.As(err, &codecErr) {
+				offset := queryStart + codecErr.Offset
+				out.Flush()
+				printInputErr(filename, position{
+					line:       lineNum,
+					column:     offset + 1,
+					text:       line,
+					textColumn: offset,
+				}, &inputError{offset: lineStart + int64(offset), err: codecErr})
+				os.Exit(2)
+			}
+			if lineNum > 1 && queryFlags.Format == flagtype.QueryFormatTable {
+				out.WriteByte('\n')
+			}
+			return writeQueryPairs(out, pairs)
+		})
+		out.Flush()
+		if err != nil {
+			printErr(err)
+			os.Exit(2)
+		}
+	},
+}
+
+// queryOfLine returns the query string of a line, which is either a query
+// string on its own, optionally starting with ?, or a whole URL.
+func queryOfLine(line string) (string, int) {
+	start := 0
+	if strings.HasPrefix(line, "?") {
+		start = 1
+	} else if strings.Contains(line, "://") {
+		i := strings.IndexByte(line, '?')
+		if i == -1 {
+			return "", len(line)
+		}
+		start = i + 1
+	}
+	query := line[start:]
+	if start > 0 {
+		query, _, _ = strings.Cut(query, "#")
+	}
+	return query, start
+}
+
+func writeQueryPairs(w io.Writer, pairs []codec.QueryPair) error {
+	switch queryFlags.Format {
+	case flagtype.QueryFormatJSON:
+		if pairs == nil {
+			pairs = []codec.QueryPair{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(pairs)
+	case flagtype.QueryFormatLines:
+		for _, pair := range pairs {
+			fmt.Fprint(w, pair.Key)
+			if pair.HasValue {
+				fmt.Fprint(w, "=", pair.Value)
+			}
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+	default:
+		width := 0
+		for _, pair := range pairs {
+			if len(pair.Key) > width {
+				width = len(pair.Key)
+			}
+		}
+		for _, pair := range pairs {
+			flagNameColor.Fprint(w, pair.Key)
+			fmt.Fprint(w, strings.Repeat(" ", width-len(pair.Key)+2))
+			if _, err := fmt.Fprintln(w, pair.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var buildQueryCmd = &cobra.Command{
+	Use:   "build-query [file]",
+	Short: "Encodes key and value pairs into a query string",
+	Long: `Encodes key and value pairs from the input into a query string, and
+prints it to STDOUT. The input is either key=value lines, a JSON object
+such as {"a": "1", "b": ["x", "y"]}, or a JSON array of {"key", "value"}
+objects as printed by "parse-query --format json".`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		reader, _, err := openInput(args)
+		if err != nil {
+			printErr(err)
+			os.Exit(3)
+		}
+		defer reader.Close()
+
+		input, err := io.ReadAll(reader)
+		if err != nil {
+			printErr(err)
+			os.Exit(2)
+		}
+		var pairs []codec.QueryPair
+		trimmed := bytes.TrimSpace(input)
+		if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			pairs, err = parseJSONQueryPairs(trimmed)
+		} else {
+			err = eachLine(bytes.NewReader(input), func(line string, _ int64) error {
+				if line != "" {
+					key, value, hasValue := strings.Cut(line, "=")
+					pairs = append(pairs, codec.QueryPair{Key: key, Value: value, HasValue: hasValue})
+				}
+				return nil
+			})
+		}
+		if err != nil {
+			printErr(err)
+			os.Exit(2)
+		}
+		fmt.Fprintln(stdout, codec.BuildQuery(pairs))
+	},
+}
+
+// parseJSONQueryPairs parses either a JSON object, keeping the order of its
+// keys, or a JSON array of {"key", "value"} objects.
+func parseJSONQueryPairs(input []byte) ([]codec.QueryPair, error) {
+	if input[0] == '[' {
+		var list []struct {
+			Key   string          `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(input, &list); err != nil {
+			return nil, err
+		}
+		pairs := make([]codec.QueryPair, 0, len(list))
+		for _, item := range list {
+			value, hasValue, err := jsonQueryValue(item.Value)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", item.Key, err)
+			}
+			pairs = append(pairs, codec.QueryPair{Key: item.Key, Value: value, HasValue: hasValue})
+		}
+		return pairs, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(input))
+	dec.UseNumber()
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var pairs []codec.QueryPair
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := tok.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		raw = bytes.TrimSpace(raw)
+		if len(raw) > 0 && raw[0] == '[' {
+			var values []json.RawMessage
+			if err := json.Unmarshal(raw, &values); err != nil {
+				return nil, err
+			}
+			for _, v := range values {
+				value, hasValue, err := jsonQueryValue(v)
+				if err != nil {
+					return nil, fmt.Errorf("key %q: %w", key, err)
+				}
+				pairs = append(pairs, codec.QueryPair{Key: key, Value: value, HasValue: hasValue})
+			}
+			continue
+		}
+		value, hasValue, err := jsonQueryValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key, err)
+		}
+		pairs = append(pairs, codec.QueryPair{Key: key, Value: value, HasValue: hasValue})
+	}
+	return pairs, nil
+}
+
+// jsonQueryValue converts a JSON scalar into a query value. A missing value
+// or null gives a key without value.
+func jsonQueryValue(raw json.RawMessage) (string, bool, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", false, nil
+	}
+	switch raw[0] {
+	case '"':
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, true, err
+	case '{', '[':
+		return "", false, errors.New("nested objects and arrays are not supported")
+	}
+	// Numbers and booleans are used as written in the JSON
+	return string(raw), true, nil
+}
+
+func init() {
+	parseQueryCmd.Flags().VarP(&queryFlags.Format, "format", "f", `output "table", "json", or "lines"`)
+	parseQueryCmd.RegisterFlagCompletionFunc("format", flagtype.CompleteQueryFormat)
+	rootCmd.AddCommand(parseQueryCmd)
+	rootCmd.AddCommand(buildQueryCmd)
+}
@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jilleJr/urlencode/pkg/codec"
+)
+
+// jsonRecord is the output of a single value with --output=jsonl.
+type jsonRecord struct {
+	File    string           `json:"file,omitempty"`
+	Line    int              `json:"line"`
+	Input   string           `json:"input"`
+	Output  string           `json:"output"`
+	Changed bool             `json:"changed"`
+	Escapes []jsonEscape     `json:"escapes"`
+	Layers  []string         `json:"layers,omitempty"`
+	Error   *jsonRecordError `json:"error,omitempty"`
+}
+
+// jsonEscape is a single change within a value. Offset is the byte offset of
+// From within the input value.
+type jsonEscape struct {
+	Offset int    `json:"offset"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Kind   string `json:"kind,omitempty"`
+}
+
+// jsonRecordError is a value that failed to decode with --keep-going, which
+// is then kept unchanged. Offset is the byte offset within the input value.
+type jsonRecordError struct {
+	Offset  int    `json:"offset"`
+	Message string `json:"message"`
+}
+
+// recordWriter collects the output of codec.Writer for a single value, so it
+// can be written as a jsonRecord.
+type recordWriter struct {
+	hw *highlightWriter
+	// file is set with --with-filename.
+	file   string
+	output strings.Builder
+	spans  []codec.Span
+	layers []string
+}
+
+func (w *recordWriter) reset() {
+	w.output.Reset()
+	w.spans = w.spans[:0]
+	w.layers = nil
+}
+
+func (w *recordWriter) Write(p []byte) (int, error) {
+	return w.output.Write(p)
+}
+
+func (w *recordWriter) WriteSpans(s string, spans []codec.Span) (int, error) {
+	w.hw.count(spans)
+	for _, span := range spans {
+		span.OutStart += w.output.Len()
+		span.OutEnd += w.output.Len()
+		w.spans = append(w.spans, span)
+	}
+	return w.output.WriteString(s)
+}
+
+func (w *recordWriter) WriteLayers(layers []string, more bool) error {
+	w.layers = layers
+	return w.hw.WriteLayers(layers, more)
+}
+
+// escapes returns the changes of the value. Adjacent changes are merged
+// until they are valid UTF-8, so a multi-byte character such as "ä" is a
+// single change from "ä" to "%C3%A4", instead of one change per byte.
+func (w *recordWriter) escapes(input string) []jsonEscape {
+	output := w.output.String()
+	var spans []codec.Span
+	for _, span := range w.spans {
+		if n := len(spans); n > 0 {
+			last := &spans[n-1]
+			if last.InEnd == span.InStart && last.OutEnd == span.OutStart && last.Kind == span.Kind &&
+				(!utf8.ValidString(input[last.InStart:last.InEnd]) || !utf8.ValidString(output[last.OutStart:last.OutEnd])) {
+				last.InEnd = span.InEnd
+				last.OutEnd = span.OutEnd
+				continue
+			}
+		}
+		spans = append(spans, span)
+	}
+
+	escapes := make([]jsonEscape, 0, len(spans))
+	for _, span := range spans {
+		e := jsonEscape{
+			Offset: span.InStart,
+			From:   input[span.InStart:span.InEnd],
+			To:     output[span.OutStart:span.OutEnd],
+		}
+		switch span.Kind {
+		case codec.SpanMalformed:
+			e.Kind = "malformed"
+		case codec.SpanInvalidUTF8:
+			e.Kind = "invalid-utf8"
+		}
+		escapes = append(escapes, e)
+	}
+	return escapes
+}
+
+// copyRecords encodes or decodes each record of r, separated by delim, or all
+// of r as a single value if all is set, and writes a jsonRecord for each value
+// to out. The codec.Writer must write into rw.
+func copyRecords(w *codec.Writer, rw *recordWriter, out io.Writer, r io.Reader, all bool, keep *keepGoing, delim byte) error {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	record := func(line int, start int64, input string) error {
+		rw.reset()
+		_, err := io.WriteString(w, input)
+		if err == nil {
+			err = w.Flush()
+		}
+		rec := jsonRecord{File: rw.file, Line: line, Input: input}
+		if err != nil {
+			var codecErr *codec.Error
+			if keep == nil || !errors.As(err, &codecErr) {
+				return withInputOffset(err, start)
+			}
+			keep.onError(withInputOffset(err, start))
+			keep.failedLines++
+			rec.Output = input
+			rec.Escapes = []jsonEscape{}
+			rec.Error = &jsonRecordError{Offset: codecErr.Offset, Message: codecErr.Error()}
+		} else {
+			rec.Output = rw.output.String()
+			rec.Changed = rec.Output != input
+			rec.Escapes = rw.escapes(input)
+			rec.Layers = rw.layers
+		}
+		if keep != nil {
+			keep.lines++
+		}
+		return enc.Encode(rec)
+	}
+
+	if all {
+		input, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return record(1, 0, string(input))
+	}
+	line := 0
+	return eachRecord(r, delim, func(input string, start int64) error {
+		line++
+		return record(line, start, input)
+	})
+}
@@ -19,11 +19,16 @@ package cmd
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/fatih/color"
+	"github.com/jilleJr/urlencode/pkg/codec"
 	"github.com/jilleJr/urlencode/pkg/flagtype"
 	"github.com/jilleJr/urlencode/pkg/license"
 	"github.com/mattn/go-colorable"
@@ -42,13 +47,46 @@ var versionText = fmt.Sprintf(`urlencode %s  Copyright (C) 2021  Kalle Fagerberg
 var flags = struct {
 	Encode                flagtype.Encoding
 	Decode                bool
+	Lenient               bool
+	InvalidUTF8           flagtype.InvalidUTF8
+	KeepGoing             bool
+	Recursive             bool
+	MaxDepth              int
+	ShowLayers            bool
+	Safe                  string
+	Unsafe                string
+	Set                   string
+	LowerHex              bool
+	Space                 flagtype.Space
 	AllLines              bool
 	ShowLicenseWarranty   bool
 	ShowLicenseConditions bool
 	Completions           flagtype.Shell
 	ShowCompletionsHelp   bool
+	ErrorFormat           flagtype.ErrorFormat
+	Output                flagtype.OutputFormat
+	Explain               bool
+	Interactive           bool
+	Null                  bool
+	Delimiter             string
+	Exact                 bool
+	NoNewline             bool
+	Values                []string
+	WithFilename          bool
+	OutputFile            string
+	InPlace               string
+	CSV                   bool
+	TSV                   bool
+	Columns               []string
+	JSON                  bool
+	Paths                 []string
+	Match                 string
+	Group                 int
 }{
-	Encode: flagtype.EncodePathSegment,
+	Encode:      flagtype.EncodePathSegment,
+	MaxDepth:    10,
+	ErrorFormat: flagtype.ErrorFormatText,
+	Output:      flagtype.OutputFormatText,
 }
 
 var (
@@ -57,7 +95,11 @@ var (
 
 	errProgramNameColor    = color.New(color.FgRed, color.Italic)
 	errColor               = color.New(color.FgHiRed, color.Bold)
+	warnColor              = color.New(color.FgHiYellow, color.Bold)
+	infoColor              = color.New(color.FgHiBlue, color.Bold)
 	errUseHelpFlagTipColor = color.New(color.FgHiBlack, color.Italic)
+	filenameColor          = color.New(color.FgCyan)
+	filenameSeparatorColor = color.New(color.FgHiBlack)
 )
 
 var rootCmd = &cobra.Command{
@@ -65,7 +107,7 @@ var rootCmd = &cobra.Command{
 	Short: "Encodes/decodes the input value for HTTP URLs",
 	Long: `Encodes/decodes the input value for HTTP URLs
 and prints the encoded/decoded value to STDOUT.`,
-	Args:    cobra.MaximumNArgs(1),
+	Args:    cobra.ArbitraryArgs,
 	Version: versionText,
 	Run: func(cmd *cobra.Command, args []string) {
 		if flags.ShowLicenseConditions {
@@ -100,53 +142,348 @@ and prints the encoded/decoded value to STDOUT.`,
 			return
 		}
 
-		var reader io.Reader
-		if len(args) == 0 {
-			reader = os.Stdin
-			defer os.Stdin.Close()
-		} else {
-			filename := args[0]
-			file, err := os.Open(filename)
+		if (flags.Recursive || flags.ShowLayers) && !flags.Decode {
+			printErr(errors.New("--recursive and --show-layers can only be used with --decode"))
+			os.Exit(1)
+		}
+		if flags.Explain && (flags.Decode || flags.Output == flagtype.OutputFormatJSONL) {
+			printErr(errors.New("--explain can't be used with --decode or --output=jsonl"))
+			os.Exit(1)
+		}
+		inPlace := cmd.Flags().Changed("in-place")
+		if flags.Interactive && (len(args) > 0 || len(flags.Values) > 0 || flags.OutputFile != "" || inPlace || !isTerminal(os.Stdin)) {
+			printErr(errors.New("--interactive needs a terminal on STDIN, and no file argument, --value, --output-file, or --in-place"))
+			os.Exit(1)
+		}
+		if inPlace && (len(flags.Values) > 0 || flags.OutputFile != "" || flags.WithFilename || flags.Explain || flags.Output == flagtype.OutputFormatJSONL) {
+			printErr(errors.New("--in-place can't be used with --value, --output-file, --with-filename, --explain, or --output=jsonl"))
+			os.Exit(1)
+		}
+		if inPlace && (len(args) == 0 || containsString(args, "-")) {
+			printErr(errors.New("--in-place needs files to rewrite, and can't rewrite STDIN"))
+			os.Exit(1)
+		}
+		backupSuffix := flags.InPlace
+		if backupSuffix == inPlaceNoBackup {
+			backupSuffix = ""
+		}
+		delim, err := recordDelimiter()
+		if err != nil {
+			printErr(err)
+			os.Exit(1)
+		}
+		// fieldMode is set when only some parts of each record are encoded
+		// or decoded, such as with --csv.
+		var fieldMode string
+		var csvComma byte
+		for _, mode := range []struct {
+			name  string
+			set   bool
+			comma byte
+		}{{"--csv", flags.CSV, ','}, {"--tsv", flags.TSV, '\t'}, {"--json", flags.JSON, 0}, {"--match", flags.Match != "", 0}} {
+			if !mode.set {
+				continue
+			}
+			if fieldMode != "" {
+				printErr(fmt.Errorf("%s and %s can't be used together", fieldMode, mode.name))
+				os.Exit(1)
+			}
+			fieldMode = mode.name
+			csvComma = mode.comma
+		}
+		if fieldMode != "" && (flags.AllLines || flags.Explain || flags.Interactive || flags.ShowLayers || flags.Output == flagtype.OutputFormatJSONL) {
+			printErr(fmt.Errorf("%s can't be used with --all, --explain, --interactive, --show-layers, or --output=jsonl", fieldMode))
+			os.Exit(1)
+		}
+		if (flags.CSV || flags.TSV || flags.JSON) && (flags.Null || flags.Delimiter != "") {
+			printErr(fmt.Errorf("%s can't be used with --null or --delimiter", fieldMode))
+			os.Exit(1)
+		}
+		if len(flags.Columns) > 0 && csvComma == 0 {
+			printErr(errors.New("--columns can only be used with --csv or --tsv"))
+			os.Exit(1)
+		}
+		columns, err := parseColumns(flags.Columns)
+		if err != nil {
+			printErr(err)
+			os.Exit(1)
+		}
+		if len(flags.Paths) > 0 && !flags.JSON {
+			printErr(errors.New("--path can only be used with --json"))
+			os.Exit(1)
+		}
+		var match *regexp.Regexp
+		group := flags.Group
+		if flags.Match != "" {
+			match, err = regexp.Compile(flags.Match)
+			if err != nil {
+				printErr(fmt.Errorf("invalid --match: %w", err))
+				os.Exit(1)
+			}
+			if !cmd.Flags().Changed("group") && match.NumSubexp() > 0 {
+				group = 1
+			}
+			if group < 0 || group > match.NumSubexp() {
+				printErr(fmt.Errorf("invalid --group %d, as --match has %d capture group(s)", group, match.NumSubexp()))
+				os.Exit(1)
+			}
+		} else if cmd.Flags().Changed("group") {
+			printErr(errors.New("--group can only be used with --match"))
+			os.Exit(1)
+		}
+		var selectors []jsonSelector
+		for _, path := range flags.Paths {
+			sel, err := parseJSONSelector(path)
 			if err != nil {
 				printErr(err)
-				os.Exit(3)
+				os.Exit(1)
 			}
-			reader = file
-			defer file.Close()
+			selectors = append(selectors, sel)
+		}
+		if flags.MaxDepth < 1 {
+			printErr(fmt.Errorf("--max-depth must be at least 1, but got %d", flags.MaxDepth))
+			os.Exit(1)
 		}
 
-		var scanner Scanner
-		if flags.AllLines {
-			scanner = NewReadAllScanner(reader)
-		} else {
-			scanner = bufio.NewScanner(reader)
+		opts := codec.Options{
+			Safe:        flags.Safe,
+			Unsafe:      flags.Unsafe,
+			LowerHex:    flags.LowerHex,
+			Space:       codec.Space(flags.Space),
+			Lenient:     flags.Lenient,
+			InvalidUTF8: codec.InvalidUTF8Policy(flags.InvalidUTF8),
+		}
+		if flags.Set != "" {
+			set, err := codec.ParseCharSet(flags.Set)
+			if err != nil {
+				printErr(err)
+				os.Exit(1)
+			}
+			opts.Set = &set
 		}
+		if flags.Interactive {
+			if err := runInteractive(os.Stdin, stdout, opts); err != nil {
+				printErr(err)
+				os.Exit(2)
+			}
+			return
+		}
+		if len(args) == 0 && len(flags.Values) == 0 && isTerminal(os.Stdin) {
+			printInfo(errors.New("reading from STDIN, one value per line, until Ctrl+D. Use --interactive for a prompt"))
+		}
+		c := codec.New(codec.Encoding(flags.Encode), opts)
 
-		for scanner.Scan() {
-			value := scanner.Text()
+		inputs := inputsOf(args, flags.Values)
+		// Inputs that can't be opened are skipped, like with grep, but still
+		// fail the run once the other inputs are done.
+		openFailed := false
 
-			if flags.Decode {
-				escaped, err := unescape(value, flags.Encode)
+		out := bufio.NewWriter(stdout)
+		var outFile *atomicFile
+		if flags.OutputFile != "" {
+			outFile, err = createAtomic(flags.OutputFile)
+			if err != nil {
+				printErr(err)
+				os.Exit(2)
+			}
+			out.Reset(outFile)
+		}
+		if outFile != nil || inPlace {
+			// The colors are only meant for the terminal
+			color.NoColor = true
+		}
+		// finish writes the rest of the output, and then replaces the
+		// --output-file with it, unless it failed.
+		finish := func(err error) error {
+			if flushErr := out.Flush(); err == nil {
+				err = flushErr
+			}
+			if outFile == nil {
+				return err
+			}
+			if err != nil {
+				outFile.Discard()
+				return err
+			}
+			return outFile.Commit("")
+		}
+		if flags.Explain {
+			for i, in := range inputs {
+				reader, openErr := in.open()
+				if openErr != nil {
+					printErr(openErr)
+					openFailed = true
+					continue
+				}
+				if i > 0 {
+					fmt.Fprintln(out)
+				}
+				if flags.WithFilename {
+					fmt.Fprintln(out, filenameColor.Sprint(in.name)+":")
+				}
+				err = explainInput(c, out, reader, flags.AllLines || in.value, delim)
+				reader.Close()
 				if err != nil {
-					printErr(err)
-					os.Exit(2)
+					break
 				}
-				fmt.Fprint(stdout, escaped)
+			}
+			if err := finish(err); err != nil {
+				printErr(err)
+				os.Exit(2)
+			}
+			if openFailed {
+				os.Exit(3)
+			}
+			return
+		}
+		hw := &highlightWriter{w: out, color: escapedColor}
+		if flags.Decode {
+			hw.color = unescapedColor
+		}
+		// With --output=jsonl, the output of each value is collected by rw
+		// instead, and hw is only used to count the changes.
+		var rw *recordWriter
+		var target io.Writer = hw
+		if flags.Output == flagtype.OutputFormatJSONL {
+			rw = &recordWriter{hw: hw}
+			target = rw
+		} else {
+			hw.showLayers = flags.ShowLayers
+		}
+		newWriter := func(target io.Writer) *codec.Writer {
+			if flags.Recursive || flags.ShowLayers {
+				return c.NewRecursiveDecoder(target, flags.MaxDepth)
+			} else if flags.Decode {
+				return c.NewDecoder(target)
+			}
+			return c.NewEncoder(target)
+		}
+		w := newWriter(target)
+		// With --csv, --tsv, --json, or --match, only some fields of each record are
+		// encoded or decoded, one at a time through fw.
+		var fw *fieldWriter
+		if fieldMode != "" {
+			fw = &fieldWriter{rw: &recordWriter{hw: hw}}
+			fw.w = newWriter(fw.rw)
+		}
+
+		// pr and filename are those of the input being read.
+		var pr *positionReader
+		var filename string
+		reportErr := func(err error) {
+			var inputErr *inputError
+			if errors.As(err, &inputErr) {
+				printInputErr(filename, pr.position(inputErr.offset), inputErr)
 			} else {
-				fmt.Fprint(stdout, escape(value, flags.Encode))
+				printErr(err)
 			}
+		}
 
-			fmt.Println()
+		var keep *keepGoing
+		if flags.KeepGoing {
+			keep = &keepGoing{onError: reportErr}
+			hw.w = &keep.buf
+			if fw != nil {
+				fw.keep = keep
+			}
 		}
 
-		if err := scanner.Err(); err != nil {
-			printErr(err)
+		for i, in := range inputs {
+			reader, openErr := in.open()
+			if openErr != nil {
+				printErr(openErr)
+				openFailed = true
+				continue
+			}
+			var inPlaceFile *atomicFile
+			if inPlace {
+				inPlaceFile, err = createAtomic(in.name)
+				if err != nil {
+					reader.Close()
+					break
+				}
+				out.Reset(inPlaceFile)
+			}
+			pr = newPositionReader(reader)
+			filename = in.name
+			format := recordFormat{
+				delim:     delim,
+				exact:     flags.Exact,
+				noNewline: flags.NoNewline && (inPlace || i == len(inputs)-1),
+			}
+			if flags.WithFilename {
+				format.prefix = filenameColor.Sprint(in.name) + filenameSeparatorColor.Sprint(":")
+				if rw != nil {
+					rw.file = in.name
+				}
+			}
+			if match != nil {
+				err = copyMatches(fw, out, pr, match, group, format)
+			} else if flags.JSON {
+				err = copyJSON(fw, out, pr, in.name, selectors, format)
+			} else if fw != nil {
+				err = copyCSV(fw, out, pr, in.name, csvComma, columns, format)
+			} else if rw != nil {
+				err = copyRecords(w, rw, out, pr, flags.AllLines || in.value, keep, delim)
+			} else if flags.AllLines || in.value {
+				err = copyAll(w, out, pr, keep, format)
+			} else {
+				err = copyLines(w, out, pr, keep, format)
+			}
+			// The line endings are kept as-is when only some fields are
+			// changed, but a --value is still written with a newline after it
+			if err == nil && fw != nil && in.value && !format.noNewline {
+				_, err = io.WriteString(out, "\n")
+			}
+			reader.Close()
+			if inPlaceFile != nil {
+				if flushErr := out.Flush(); err == nil {
+					err = flushErr
+				}
+				if err == nil {
+					err = inPlaceFile.Commit(backupSuffix)
+				} else {
+					inPlaceFile.Discard()
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		err = finish(err)
+		if hw.malformed > 0 {
+			printWarn(fmt.Errorf("kept %d malformed escape sequence(s) as-is", hw.malformed))
+		}
+		if hw.invalid > 0 {
+			if flags.InvalidUTF8 == flagtype.InvalidUTF8KeepEscaped {
+				printWarn(fmt.Errorf("kept %d invalid UTF-8 byte(s) escaped", hw.invalid))
+			} else {
+				printWarn(fmt.Errorf("replaced %d invalid UTF-8 byte(s) with U+FFFD", hw.invalid))
+			}
+		}
+		if hw.stopped > 0 {
+			printWarn(fmt.Errorf("stopped at --max-depth of %d layer(s), but %d value(s) are still encoded", flags.MaxDepth, hw.stopped))
+		} else if hw.values == 1 && !hw.showLayers && rw == nil {
+			printInfo(fmt.Errorf("decoded %d layer(s)", hw.maxLayers))
+		} else if hw.values > 1 && !hw.showLayers && rw == nil {
+			printInfo(fmt.Errorf("decoded up to %d layer(s) per line", hw.maxLayers))
+		}
+		if err != nil {
+			reportErr(err)
 			os.Exit(2)
 		}
+		if keep != nil && keep.failedLines > 0 {
+			printErr(fmt.Errorf("%d of %d lines failed, and were kept unchanged", keep.failedLines, keep.lines))
+			os.Exit(4)
+		}
+		if openFailed {
+			os.Exit(3)
+		}
 	},
 }
 
 func Execute() {
+	rootCmd.SetArgs(attachInPlaceSuffix(os.Args[1:]))
 	err := rootCmd.Execute()
 	if err != nil {
 		printErr(err)
@@ -157,10 +494,18 @@ func Execute() {
 func init() {
 	rootCmd.SetHelpFunc(func(c *cobra.Command, args []string) {
 		fmt.Fprintln(stderr, versionText)
+		if c != rootCmd {
+			fmt.Fprintln(stderr, commandUsageMessage(c))
+			fmt.Fprint(stderr, flagsMessage(c))
+			return
+		}
 		fmt.Fprintln(stderr, sampleUsageMessage())
+		fmt.Fprintln(stderr, commandsMessage(c))
 		fmt.Fprintln(stderr, flagsMessage(c))
 		fmt.Fprint(stderr, encodingsMessage())
 	})
+	// We have our own --completion flag
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
 	// We have our own error handling in Execute()
 	rootCmd.SilenceErrors = true
 	// Only print help if calling with --help
@@ -169,7 +514,44 @@ func init() {
 	rootCmd.Flags().VarP(&flags.Encode, "encoding", "e", "encode/decode format")
 	rootCmd.RegisterFlagCompletionFunc("encoding", flagtype.CompleteEncoding)
 	rootCmd.Flags().BoolVarP(&flags.Decode, "decode", "d", false, "decodes, instead of encodes")
+	rootCmd.Flags().BoolVar(&flags.Lenient, "lenient", false, "when decoding, keep malformed escape sequences as-is")
+	rootCmd.Flags().Var(&flags.InvalidUTF8, "invalid-utf8", `when decoding, "error", "replace", or "keep-escaped" invalid UTF-8 (default: keep as-is)`)
+	rootCmd.RegisterFlagCompletionFunc("invalid-utf8", flagtype.CompleteInvalidUTF8)
+	rootCmd.Flags().BoolVarP(&flags.Recursive, "recursive", "r", false, "when decoding, keep decoding until the value no longer changes")
+	rootCmd.Flags().IntVar(&flags.MaxDepth, "max-depth", flags.MaxDepth, "max number of layers to decode with --recursive")
+	rootCmd.Flags().BoolVar(&flags.ShowLayers, "show-layers", false, "print each decoded layer on its own line (implies --recursive)")
+	rootCmd.Flags().BoolVarP(&flags.KeepGoing, "keep-going", "k", false, "keep lines that fail to decode unchanged, and continue")
+	rootCmd.Flags().StringVar(&flags.Safe, "safe", "", "characters to never escape")
+	rootCmd.Flags().StringVar(&flags.Unsafe, "unsafe", "", "characters to always escape")
+	rootCmd.Flags().StringVar(&flags.Set, "set", "", `custom set of characters to not escape, e.g "alnum,-._~"`)
+	rootCmd.Flags().BoolVar(&flags.LowerHex, "lower-hex", false, "use lowercase hex digits, e.g %2f instead of %2F")
+	rootCmd.Flags().Var(&flags.Space, "space", `escape space as "plus" or "percent" (default depends on encoding)`)
+	rootCmd.RegisterFlagCompletionFunc("space", flagtype.CompleteSpace)
 	rootCmd.Flags().BoolVarP(&flags.AllLines, "all", "a", false, "use all input at once, instead of line-by-line")
+	rootCmd.Flags().StringArrayVarP(&flags.Values, "value", "v", nil, "use this text as a value, instead of reading STDIN (can be repeated)")
+	rootCmd.Flags().BoolVarP(&flags.WithFilename, "with-filename", "H", false, `write the file name before each value, like "file.txt:a%20b"`)
+	rootCmd.Flags().StringVarP(&flags.OutputFile, "output-file", "o", "", "write the output to this file, which is only replaced once all of it is written")
+	rootCmd.Flags().StringVarP(&flags.InPlace, "in-place", "i", "", `rewrite the files in place, keeping a backup if given a suffix, as in -i.bak`)
+	rootCmd.Flags().Lookup("in-place").NoOptDefVal = inPlaceNoBackup
+	rootCmd.Flags().BoolVarP(&flags.Interactive, "interactive", "I", false, "type values at a prompt, and switch encoding with commands like :mode")
+	rootCmd.Flags().BoolVar(&flags.Explain, "explain", false, "print a table of why each character is escaped, or not")
+	rootCmd.Flags().Var(&flags.Output, "output", `print the output as "text", or as "jsonl" with one JSON object per value`)
+	rootCmd.RegisterFlagCompletionFunc("output", flagtype.CompleteOutputFormat)
+	rootCmd.Flags().BoolVar(&flags.CSV, "csv", false, "read the input as CSV, and only encode/decode the fields, keeping the quoting as-is")
+	rootCmd.Flags().BoolVar(&flags.TSV, "tsv", false, "read the input as tab-separated values, like --csv")
+	rootCmd.Flags().StringSliceVar(&flags.Columns, "columns", nil, `only encode/decode these columns with --csv or --tsv, by number or header name, e.g "2,5" or "url"`)
+	rootCmd.Flags().BoolVar(&flags.JSON, "json", false, "read the input as JSON or NDJSON, and only encode/decode its string values")
+	rootCmd.Flags().StringArrayVar(&flags.Paths, "path", nil, `only encode/decode the strings at this path with --json, e.g ".items[].url" or ".query.*" (can be repeated)`)
+	rootCmd.Flags().StringVar(&flags.Match, "match", "", `only encode/decode the parts of each line matching this regex, e.g "q=([^&]*)"`)
+	rootCmd.Flags().IntVar(&flags.Group, "group", 0, "capture group of --match to encode/decode, where 0 is the whole match (default: 1 if --match has groups)")
+	// The default depends on --match, as told by the usage above
+	rootCmd.Flags().Lookup("group").DefValue = ""
+	rootCmd.Flags().BoolVarP(&flags.Null, "null", "0", false, "records are separated by NUL instead of newline, as with find -print0")
+	rootCmd.Flags().StringVar(&flags.Delimiter, "delimiter", "", `records are separated by this character instead of newline, e.g "&" or "\t"`)
+	rootCmd.Flags().BoolVar(&flags.Exact, "exact", false, "keep line endings as they are in the input, such as \\r\\n, and never add any")
+	rootCmd.Flags().BoolVarP(&flags.NoNewline, "no-newline", "n", false, "do not write a newline after the last value")
+	rootCmd.Flags().Var(&flags.ErrorFormat, "error-format", `print errors as "text" or "json"`)
+	rootCmd.RegisterFlagCompletionFunc("error-format", flagtype.CompleteErrorFormat)
 	rootCmd.Flags().Var(&flags.Completions, "completion", `generate shell completions (for "bash", "zsh", "fish", or "powershell")`)
 	rootCmd.RegisterFlagCompletionFunc("completion", flagtype.CompleteShell)
 	rootCmd.Flags().BoolVar(&flags.ShowCompletionsHelp, "help-completion", false, "help for adding shell completions")
@@ -180,42 +562,102 @@ func init() {
 	rootCmd.Flags().MarkHidden("license-w")
 }
 
-func printErr(err error) {
-	fmt.Fprintln(stderr, errProgramNameColor.Sprint("urlencode:"), errColor.Sprint("err:"), err)
-	fmt.Fprintln(stderr, errUseHelpFlagTipColor.Sprintf(`tip: Call "%s --help" to see usage`, os.Args[0]))
-}
+// inPlaceNoBackup is the value of --in-place when given without a suffix. A
+// NUL can't be part of a file name, so it can't be mistaken for a suffix.
+const inPlaceNoBackup = "\x00"
 
-type Scanner interface {
-	Scan() bool
-	Text() string
-	Err() error
+// attachInPlaceSuffix rewrites a sed style -iSUFFIX, such as -i.bak, into
+// -i=SUFFIX, as pflag would otherwise read the suffix as more shorthand
+// flags. Values of other flags, such as "-i.bak" in "-v -i.bak", are kept
+// as they are.
+func attachInPlaceSuffix(args []string) []string {
+	if cmd, _, err := rootCmd.Find(args); err != nil || cmd != rootCmd {
+		return args
+	}
+	fs := rootCmd.Flags()
+	result := append([]string(nil), args...)
+	for i := 0; i < len(result); i++ {
+		arg := result[i]
+		switch {
+		case arg == "--":
+			return result
+		case strings.HasPrefix(arg, "--"):
+			// Skip the value of the flag, if it's not attached with "="
+			flag := fs.Lookup(arg[2:])
+			if flag != nil && flag.NoOptDefVal == "" {
+				i++
+			}
+		case strings.HasPrefix(arg, "-"):
+			for j := 1; j < len(arg); j++ {
+				flag := fs.ShorthandLookup(arg[j : j+1])
+				if flag == nil {
+					break
+				}
+				if flag.Name == "in-place" {
+					switch suffix := arg[j+1:]; {
+					case suffix == "=":
+						// pflag reads "-i=" as -i followed by a -= flag
+						result[i] = arg[:j+1]
+					case suffix != "" && suffix[0] != '=':
+						result[i] = arg[:j+1] + "=" + suffix
+					}
+					break
+				}
+				if flag.NoOptDefVal == "" {
+					// The rest of arg, or else the next arg, is the value
+					if j+1 == len(arg) {
+						i++
+					}
+					break
+				}
+			}
+		}
+	}
+	return result
 }
 
-type readAllScanner struct {
-	reader io.Reader
-	bytes  []byte
-	err    error
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
 
-func NewReadAllScanner(reader io.Reader) Scanner {
-	return &readAllScanner{
-		reader: reader,
+// recordDelimiter returns the byte that separates records in the input, from
+// the --null and --delimiter flags.
+func recordDelimiter() (byte, error) {
+	switch {
+	case flags.Null && flags.Delimiter != "":
+		return 0, errors.New("--null and --delimiter can't be used together")
+	case flags.Null:
+		return 0, nil
+	case flags.Delimiter == "":
+		return '\n', nil
+	case flags.Delimiter == `\0`:
+		return 0, nil
 	}
+	delim, err := strconv.Unquote(`"` + flags.Delimiter + `"`)
+	if err != nil || len(delim) != 1 {
+		return 0, fmt.Errorf("invalid --delimiter %q, must be a single byte, such as \"&\" or \"\\t\"", flags.Delimiter)
+	}
+	return delim[0], nil
 }
 
-func (s *readAllScanner) Scan() bool {
-	if s.bytes != nil {
-		return false
+func printErr(err error) {
+	if flags.ErrorFormat == flagtype.ErrorFormatJSON {
+		printJSONErr(jsonError{Message: err.Error()})
+		return
 	}
-
-	s.bytes, s.err = io.ReadAll(s.reader)
-	return s.err == nil
+	fmt.Fprintln(stderr, errProgramNameColor.Sprint("urlencode:"), errColor.Sprint("err:"), err)
+	fmt.Fprintln(stderr, errUseHelpFlagTipColor.Sprintf(`tip: Call "%s --help" to see usage`, os.Args[0]))
 }
 
-func (s *readAllScanner) Text() string {
-	return string(s.bytes)
+func printInfo(err error) {
+	fmt.Fprintln(stderr, errProgramNameColor.Sprint("urlencode:"), infoColor.Sprint("info:"), err)
 }
 
-func (s *readAllScanner) Err() error {
-	return s.err
+func printWarn(err error) {
+	fmt.Fprintln(stderr, errProgramNameColor.Sprint("urlencode:"), warnColor.Sprint("warn:"), err)
 }
@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAttachInPlaceSuffix(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-i", "a.txt"}, []string{"-i", "a.txt"}},
+		{[]string{"-i.bak", "a.txt"}, []string{"-i=.bak", "a.txt"}},
+		{[]string{"-i=.bak", "a.txt"}, []string{"-i=.bak", "a.txt"}},
+		{[]string{"-i=", "a.txt"}, []string{"-i", "a.txt"}},
+		{[]string{"-di.bak", "a.txt"}, []string{"-di=.bak", "a.txt"}},
+		{[]string{"--in-place=.bak", "a.txt"}, []string{"--in-place=.bak", "a.txt"}},
+		// Values of other flags are left alone
+		{[]string{"-v", "-i.bak"}, []string{"-v", "-i.bak"}},
+		{[]string{"--value", "-i.bak"}, []string{"--value", "-i.bak"}},
+		{[]string{"-vi.bak", "-i.bak"}, []string{"-vi.bak", "-i=.bak"}},
+		{[]string{"-d", "-i.bak"}, []string{"-d", "-i=.bak"}},
+		// As are arguments after "--", and those of other commands
+		{[]string{"--", "-i.bak"}, []string{"--", "-i.bak"}},
+		{[]string{"parse-query", "-i.bak"}, []string{"parse-query", "-i.bak"}},
+	}
+	for _, tc := range tests {
+		got := attachInPlaceSuffix(tc.args)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("attachInPlaceSuffix(%q): want %q, got %q", tc.args, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package codec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CharSet is a set of bytes.
+type CharSet [256]bool
+
+var charClasses = map[string]string{
+	"alpha":      "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"lower":      "abcdefghijklmnopqrstuvwxyz",
+	"upper":      "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"digit":      "0123456789",
+	"alnum":      "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+	"unreserved": "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-._~",
+	"gen-delims": ":/?#[]@",
+	"sub-delims": "!$&'()*+,;=",
+	"reserved":   ":/?#[]@!$&'()*+,;=",
+	"comma":      ",",
+	"space":      " ",
+}
+
+// charClassNames are the names of charClasses, in the order they are listed
+// in errors.
+var charClassNames = []string{
+	"alpha", "lower", "upper", "digit", "alnum", "unreserved", "gen-delims",
+	"sub-delims", "reserved", "comma", "space",
+}
+
+// ParseCharSet parses a comma-separated list of character classes and
+// literal characters, such as "alnum,-._~". Valid classes are alpha, lower,
+// upper, digit, alnum, unreserved, gen-delims, sub-delims, reserved, comma,
+// and space. Any other word is rejected, so that a misspelled class is not
+// taken as its letters; letters are instead listed one by one, as "a,b,c".
+func ParseCharSet(expr string) (CharSet, error) {
+	var set CharSet
+	for _, item := range strings.Split(expr, ",") {
+		if item == "" {
+			return set, fmt.Errorf("invalid character set %q: empty item, use \"comma\" to add a comma", expr)
+		}
+		if chars, ok := charClasses[item]; ok {
+			set.Add(chars)
+		} else if len(item) > 1 && isWord(item) {
+			return set, fmt.Errorf("invalid character set %q: unknown class %q, must be one of %s, or list letters one by one, such as \"a,b,c\"",
+				expr, item, strings.Join(charClassNames, ", "))
+		} else {
+			set.Add(item)
+		}
+	}
+	return set, nil
+}
+
+// isWord reports if s only has letters, digits, and hyphens, and at least
+// one letter, like the names of the character classes.
+func isWord(s string) bool {
+	letter := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+			letter = true
+		case '0' <= c && c <= '9' || c == '-':
+		default:
+			return false
+		}
+	}
+	return letter
+}
+
+// Contains reports whether b is in the set.
+func (s *CharSet) Contains(b byte) bool {
+	return s[b]
+}
+
+// Add adds all bytes of chars to the set.
+func (s *CharSet) Add(chars string) {
+	for i := 0; i < len(chars); i++ {
+		s[chars[i]] = true
+	}
+}
+
+// Remove removes all bytes of chars from the set.
+func (s *CharSet) Remove(chars string) {
+	for i := 0; i < len(chars); i++ {
+		s[chars[i]] = false
+	}
+}
@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package codec
+
+import "testing"
+
+func TestParseCharSet(t *testing.T) {
+	tests := []struct {
+		expr string
+		in   string
+		out  string
+	}{
+		{"alnum,-._~", "aZ9-._~", "/ !"},
+		{"digit,x,y", "09xy", "az-"},
+		{"comma,space", ", ", "a"},
+		{"ab!", "ab!", "c"},
+	}
+	for _, tc := range tests {
+		set, err := ParseCharSet(tc.expr)
+		if err != nil {
+			t.Errorf("ParseCharSet(%q): unexpected error: %v", tc.expr, err)
+			continue
+		}
+		for i := 0; i < len(tc.in); i++ {
+			if !set.Contains(tc.in[i]) {
+				t.Errorf("ParseCharSet(%q): want %q in the set", tc.expr, tc.in[i])
+			}
+		}
+		for i := 0; i < len(tc.out); i++ {
+			if set.Contains(tc.out[i]) {
+				t.Errorf("ParseCharSet(%q): want %q not in the set", tc.expr, tc.out[i])
+			}
+		}
+	}
+}
+
+func TestParseCharSetErrors(t *testing.T) {
+	for _, expr := range []string{"", "alnum,", "bogus", "alnum,Digit", "a-z"} {
+		if _, err := ParseCharSet(expr); err == nil {
+			t.Errorf("ParseCharSet(%q): want an error", expr)
+		}
+	}
+}
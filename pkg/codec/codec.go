@@ -0,0 +1,588 @@
+// SPDX-FileCopyrightText: The Go Authors. All rights reserved.
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package codec implements the URL encoding and decoding rules used by the
+// urlencode command, so they can be reused from other Go programs.
+package codec
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// The code in this file has been taken from the source code of the `net/url`
+// Go package, v1.17.1.
+
+const (
+	upperHex = "0123456789ABCDEF"
+	lowerHex = "0123456789abcdef"
+)
+
+// SpanKind tells what kind of change a Span marks.
+type SpanKind int
+
+const (
+	// SpanChanged is a part that was encoded or decoded.
+	SpanChanged SpanKind = iota
+	// SpanMalformed is an invalid escape sequence, or a byte not allowed in
+	// a host, that was kept as-is when decoding leniently.
+	SpanMalformed
+	// SpanInvalidUTF8 is a byte that is not valid UTF-8 after decoding,
+	// which was replaced or kept escaped by the InvalidUTF8 option.
+	SpanInvalidUTF8
+)
+
+// Span marks a part of the input that was changed, together with the part
+// of the output it was changed into. All offsets are in bytes.
+type Span struct {
+	InStart, InEnd   int
+	OutStart, OutEnd int
+	Kind             SpanKind
+}
+
+// Error is returned when decoding fails, and tells where in the input the
+// invalid part is. Err is either a url.EscapeError, url.InvalidHostError,
+// InvalidUTF8Error, or *LabelError.
+type Error struct {
+	// Offset is the byte offset of the invalid part in the input.
+	Offset int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Len returns the length in bytes of the invalid part of the input.
+func (e *Error) Len() int {
+	switch err := e.Err.(type) {
+	case url.EscapeError:
+		return len(err)
+	case url.InvalidHostError:
+		return len(err)
+	case InvalidUTF8Error:
+		return len(err)
+	case *LabelError:
+		return len(err.Label)
+	}
+	return 1
+}
+
+// Options tweaks the rules of an encoding.
+type Options struct {
+	// Set, when non-nil, replaces the encoding's set of characters that are
+	// left unescaped.
+	Set *CharSet
+	// Safe are additional characters that are never escaped.
+	Safe string
+	// Unsafe are additional characters that are always escaped.
+	Unsafe string
+	// LowerHex makes escape sequences use lowercase hex digits, such as %2f
+	// instead of %2F.
+	LowerHex bool
+	// Space decides if space is escaped as + or %20. By default, only the
+	// query encoding uses +.
+	Space Space
+	// Lenient makes decoding keep invalid escape sequences as-is, instead of
+	// failing. They are reported as spans of kind SpanMalformed.
+	Lenient bool
+	// InvalidUTF8 decides what to do with decoded values that are not valid
+	// UTF-8. By default, they are left as they are.
+	InvalidUTF8 InvalidUTF8Policy
+}
+
+// Codec encodes and decodes values using an encoding and its options.
+type Codec struct {
+	mode        Encoding
+	opts        Options
+	unescaped   CharSet
+	hex         string
+	spaceAsPlus bool
+	lenient     bool
+	invalidUTF8 InvalidUTF8Policy
+	// utf16 escapes UTF-16 code units instead of UTF-8 bytes, as %uXXXX
+	utf16 bool
+	// iri only decodes escape sequences that are safe to show in an IRI
+	iri bool
+	// idna converts labels of domain names to and from Punycode
+	idna bool
+	// components are the codecs used for each component of a whole URL
+	components map[Encoding]*Codec
+}
+
+// New returns a Codec for the given encoding, tweaked by the options.
+func New(mode Encoding, opts Options) *Codec {
+	c := &Codec{
+		mode:        mode,
+		opts:        opts,
+		unescaped:   encodingSet(mode),
+		hex:         upperHex,
+		utf16:       mode == EncodeJSEscape,
+		iri:         mode == EncodeIRI,
+		idna:        mode == EncodeHost,
+		lenient:     opts.Lenient,
+		invalidUTF8: opts.InvalidUTF8,
+	}
+	if opts.Set != nil {
+		c.unescaped = *opts.Set
+	}
+	if mode == EncodeURL {
+		c.components = make(map[Encoding]*Codec, len(urlComponentModes))
+		for _, m := range urlComponentModes {
+			c.components[m] = New(m, opts)
+		}
+	}
+	c.unescaped.Add(opts.Safe)
+	c.unescaped.Remove(opts.Unsafe)
+	if opts.LowerHex {
+		c.hex = lowerHex
+	}
+	switch opts.Space {
+	case SpacePlus:
+		c.spaceAsPlus = true
+	case SpacePercent:
+		c.spaceAsPlus = false
+	default:
+		switch mode {
+		case EncodeQueryComponent, EncodeWHATWGForm,
+			EncodePHPURLEncode, EncodePythonQuotePlus,
+			EncodeJavaURLEncoder:
+			c.spaceAsPlus = true
+		}
+	}
+	return c
+}
+
+var defaultCodecs sync.Map
+
+func defaultCodec(mode Encoding) *Codec {
+	if c, ok := defaultCodecs.Load(mode); ok {
+		return c.(*Codec)
+	}
+	c, _ := defaultCodecs.LoadOrStore(mode, New(mode, Options{}))
+	return c.(*Codec)
+}
+
+// encodingSet returns the characters left unescaped by the encoding.
+func encodingSet(mode Encoding) CharSet {
+	var set CharSet
+	for i := 0; i < 256; i++ {
+		escape, ok := shouldEscapeWHATWG(byte(i), mode)
+		if !ok {
+			escape, ok = shouldEscapeCompat(byte(i), mode)
+		}
+		if !ok {
+			escape, ok = shouldEscapeIRI(byte(i), mode)
+		}
+		if !ok {
+			escape = shouldEscape(byte(i), mode)
+		}
+		set[i] = !escape
+	}
+	return set
+}
+
+func (c *Codec) shouldEscape(b byte) bool {
+	return !c.unescaped[b]
+}
+
+// isHex has been copied from
+// https://cs.opensource.google/go/go/+/refs/tags/go1.17.1:src/net/url/url.go;l=47-57
+func isHex(c byte) bool {
+	switch {
+	case '0' <= c && c <= '9':
+		return true
+	case 'a' <= c && c <= 'f':
+		return true
+	case 'A' <= c && c <= 'F':
+		return true
+	}
+	return false
+}
+
+// unHex has been copied from
+// https://cs.opensource.google/go/go/+/refs/tags/go1.17.1:src/net/url/url.go;l=59-69
+func unHex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10
+	}
+	return 0
+}
+
+// shouldEscape has been copied from
+// https://cs.opensource.google/go/go/+/refs/tags/go1.17.1:src/net/url/url.go;l=100-175
+func shouldEscape(c byte, mode Encoding) bool {
+	// §2.3 Unreserved characters (alphanum)
+	if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+		return false
+	}
+
+	if mode == EncodeHost || mode == EncodeZone {
+		// §3.2.2 Host allows
+		//	sub-delims = "!" / "$" / "&" / "'" / "(" / ")" / "*" / "+" / "," / ";" / "="
+		// as part of reg-name.
+		// We add : because we include :port as part of host.
+		// We add [ ] because we include [ipv6]:port as part of host.
+		// We add < > because they're the only characters left that
+		// we could possibly allow, and Parse will reject them if we
+		// escape them (because hosts can't use %-encoding for
+		// ASCII bytes).
+		switch c {
+		case '!', '$', '&', '\'', '(', ')', '*', '+', ',', ';', '=', ':', '[', ']', '<', '>', '"':
+			return false
+		}
+	}
+
+	switch c {
+	case '-', '_', '.', '~': // §2.3 Unreserved characters (mark)
+		return false
+
+	case '$', '&', '+', ',', '/', ':', ';', '=', '?', '@': // §2.2 Reserved characters (reserved)
+		// Different sections of the URL allow a few of
+		// the reserved characters to appear unescaped.
+		switch mode {
+		case EncodePath: // §3.3
+			// The RFC allows : @ & = + $ but saves / ; , for assigning
+			// meaning to individual path segments. This package
+			// only manipulates the path as a whole, so we allow those
+			// last three as well. That leaves only ? to escape.
+			return c == '?'
+
+		case EncodePathSegment: // §3.3
+			// The RFC allows : @ & = + $ but saves / ; , for assigning
+			// meaning to individual path segments.
+			return c == '/' || c == ';' || c == ',' || c == '?'
+
+		case EncodeUserPassword: // §3.2.1
+			// The RFC allows ';', ':', '&', '=', '+', '$', and ',' in
+			// userinfo, so we must escape only '@', '/', and '?'.
+			// The parsing of userinfo treats ':' as special so we must escape
+			// that too.
+			return c == '@' || c == '/' || c == '?' || c == ':'
+
+		case EncodeQueryComponent: // §3.4
+			// The RFC reserves (so we must escape) everything.
+			return true
+
+		case EncodeFragment: // §4.1
+			// The RFC text is silent but the grammar allows
+			// everything, so escape nothing.
+			return false
+		}
+	}
+
+	if mode == EncodeFragment {
+		// RFC 3986 §2.2 allows not escaping sub-delims. A subset of sub-delims are
+		// included in reserved from RFC 2396 §2.2. The remaining sub-delims do not
+		// need to be escaped. To minimize potential breakage, we apply two restrictions:
+		// (1) we always escape sub-delims outside of the fragment, and (2) we always
+		// escape single quote to avoid breaking callers that had previously assumed that
+		// single quotes would be escaped. See issue #19917.
+		switch c {
+		case '!', '(', ')', '*':
+			return false
+		}
+	}
+
+	// Everything else must be escaped.
+	return true
+}
+
+// Unescape decodes the percent-encoded string s using the rules of the
+// given encoding.
+func Unescape(s string, mode Encoding) (string, error) {
+	return defaultCodec(mode).Unescape(s)
+}
+
+// UnescapeSpans is like Unescape, but also reports which parts of the
+// input were decoded.
+func UnescapeSpans(s string, mode Encoding) (string, []Span, error) {
+	return defaultCodec(mode).UnescapeSpans(s)
+}
+
+// Unescape decodes the percent-encoded string s.
+func (c *Codec) Unescape(s string) (string, error) {
+	t, _, err := c.UnescapeSpans(s)
+	return t, err
+}
+
+// UnescapeSpans is like Unescape, but also reports which parts of the
+// input were decoded.
+func (c *Codec) UnescapeSpans(s string) (string, []Span, error) {
+	t, spans, _, err := c.unescape(s, true)
+	return t, spans, err
+}
+
+// unescape decodes s, and unless atEOF is set it stops before a trailing
+// incomplete escape sequence instead of failing. It returns the number of
+// bytes of s it decoded. Whole URLs are only decoded once atEOF is set.
+func (c *Codec) unescape(s string, atEOF bool) (string, []Span, int, error) {
+	if c.components != nil {
+		if !atEOF {
+			return "", nil, 0, nil
+		}
+		t, spans, err := c.unescapeURL(s)
+		return t, spans, len(s), err
+	}
+	var t string
+	var spans []Span
+	var n int
+	var err error
+	if c.utf16 {
+		t, spans, n, err = c.unescapeUTF16(s, atEOF)
+	} else if c.iri {
+		t, spans, n, err = c.unescapeIRI(s, atEOF)
+	} else if c.idna {
+		t, spans, n, err = c.unescapeHost(s, atEOF)
+	} else {
+		t, spans, n, err = c.unescapeBytes(s, atEOF)
+	}
+	if err != nil || c.invalidUTF8 == "" {
+		return t, spans, n, err
+	}
+	return c.checkUTF8(s, t, spans, n, atEOF)
+}
+
+// unescapeBytes has been copied and modified from
+// https://cs.opensource.google/go/go/+/refs/tags/go1.17.1:src/net/url/url.go;l=199-270
+func (c *Codec) unescapeBytes(s string, atEOF bool) (string, []Span, int, error) {
+	// Count %, check that they're well-formed.
+	n := 0
+	hasPlus := false
+	malformed := false
+	end := len(s)
+	for i := 0; i < end; {
+		switch s[i] {
+		case '%':
+			if i+2 >= len(s) && !atEOF {
+				end = i
+				break
+			}
+			if err := c.checkEscape(s, i); err != nil {
+				if !c.lenient {
+					return "", nil, 0, &Error{Offset: i, Err: err}
+				}
+				malformed = true
+				i++
+				break
+			}
+			n++
+			i += 3
+		case '+':
+			hasPlus = c.spaceAsPlus
+			i++
+		default:
+			if err := c.checkHostByte(s, i); err != nil {
+				if !c.lenient {
+					return "", nil, 0, &Error{Offset: i, Err: err}
+				}
+				malformed = true
+			}
+			i++
+		}
+	}
+	s = s[:end]
+
+	if n == 0 && !hasPlus && !malformed {
+		return s, nil, end, nil
+	}
+
+	var t strings.Builder
+	var spans []Span
+	t.Grow(len(s) - 2*n)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '%':
+			if c.checkEscape(s, i) != nil {
+				// Keep the % and any hex digits after it as-is.
+				j := i + 1
+				for j < len(s) && j < i+3 && isHex(s[j]) {
+					j++
+				}
+				spans = append(spans, Span{InStart: i, InEnd: j, OutStart: t.Len(), OutEnd: t.Len() + j - i, Kind: SpanMalformed})
+				t.WriteString(s[i:j])
+				i = j - 1
+				continue
+			}
+			spans = append(spans, Span{InStart: i, InEnd: i + 3, OutStart: t.Len(), OutEnd: t.Len() + 1})
+			t.WriteByte(unHex(s[i+1])<<4 | unHex(s[i+2]))
+			i += 2
+		case '+':
+			if c.spaceAsPlus {
+				spans = append(spans, Span{InStart: i, InEnd: i + 1, OutStart: t.Len(), OutEnd: t.Len() + 1})
+				t.WriteByte(' ')
+			} else {
+				t.WriteByte('+')
+			}
+		default:
+			if c.checkHostByte(s, i) != nil {
+				spans = append(spans, Span{InStart: i, InEnd: i + 1, OutStart: t.Len(), OutEnd: t.Len() + 1, Kind: SpanMalformed})
+			}
+			t.WriteByte(s[i])
+		}
+	}
+	return t.String(), spans, end, nil
+}
+
+// checkEscape reports if the escape sequence at s[i] is invalid.
+//
+// checkEscape has been copied and modified from
+// https://cs.opensource.google/go/go/+/refs/tags/go1.17.1:src/net/url/url.go;l=204-240
+func (c *Codec) checkEscape(s string, i int) error {
+	if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+		s = s[i:]
+		if len(s) > 3 {
+			s = s[:3]
+		}
+		return url.EscapeError(s)
+	}
+	// Per https://tools.ietf.org/html/rfc3986#page-21
+	// in the host component %-encoding can only be used
+	// for non-ASCII bytes.
+	// But https://tools.ietf.org/html/rfc6874#section-2
+	// introduces %25 being allowed to escape a percent sign
+	// in IPv6 scoped-address literals. Yay.
+	if c.mode == EncodeHost && unHex(s[i+1]) < 8 && s[i:i+3] != "%25" {
+		return url.EscapeError(s[i : i+3])
+	}
+	if c.mode == EncodeZone {
+		// RFC 6874 says basically "anything goes" for zone identifiers
+		// and that even non-ASCII can be redundantly escaped,
+		// but it seems prudent to restrict %-escaped bytes here to those
+		// that are valid host name bytes in their unescaped form.
+		// That is, you can use escaping in the zone identifier but not
+		// to introduce bytes you couldn't just write directly.
+		// But Windows puts spaces here! Yay.
+		v := unHex(s[i+1])<<4 | unHex(s[i+2])
+		if s[i:i+3] != "%25" && v != ' ' && shouldEscape(v, EncodeHost) {
+			return url.EscapeError(s[i : i+3])
+		}
+	}
+	return nil
+}
+
+// checkHostByte reports if the unescaped byte at s[i] is not allowed in a
+// host or zone.
+func (c *Codec) checkHostByte(s string, i int) error {
+	if (c.mode == EncodeHost || c.mode == EncodeZone) && s[i] < 0x80 && shouldEscape(s[i], c.mode) {
+		return url.InvalidHostError(s[i : i+1])
+	}
+	return nil
+}
+
+// Escape percent-encodes the string s using the rules of the given encoding.
+// See Codec.Escape.
+func Escape(s string, mode Encoding) string {
+	return defaultCodec(mode).Escape(s)
+}
+
+// EscapeSpans is like Escape, but also reports which parts of the input
+// were encoded, and if encoding failed. See Codec.EscapeSpans.
+func EscapeSpans(s string, mode Encoding) (string, []Span, error) {
+	return defaultCodec(mode).EscapeSpans(s)
+}
+
+// Escape percent-encodes the string s. It ignores the errors reported by
+// EscapeSpans, and returns the same string.
+func (c *Codec) Escape(s string) string {
+	t, _, _ := c.EscapeSpans(s)
+	return t
+}
+
+// EscapeSpans is like Escape, but also reports which parts of the input
+// were encoded, and if encoding failed.
+//
+// Encoding only fails for labels of domain names in the host and url
+// encodings that can't be converted into Punycode, with an Error wrapping a
+// *LabelError for the first such label. The returned string has those labels percent-encoded
+// instead, but a Writer fails without writing them, like when decoding.
+func (c *Codec) EscapeSpans(s string) (string, []Span, error) {
+	t, spans, _, err := c.escape(s, true)
+	return t, spans, err
+}
+
+// escape encodes s, and unless atEOF is set it may stop before a trailing
+// incomplete UTF-8 sequence. It returns the number of bytes of s it encoded.
+// Whole URLs are only encoded once atEOF is set. The returned string is
+// valid even if an error is returned.
+func (c *Codec) escape(s string, atEOF bool) (string, []Span, int, error) {
+	if c.components != nil {
+		if !atEOF {
+			return "", nil, 0, nil
+		}
+		t, spans, err := c.escapeURL(s)
+		return t, spans, len(s), err
+	}
+	if c.utf16 {
+		t, spans, n := c.escapeUTF16(s, atEOF)
+		return t, spans, n, nil
+	}
+	if c.idna {
+		return c.escapeHost(s, atEOF)
+	}
+	t, spans := c.escapeBytes(s)
+	return t, spans, len(s), nil
+}
+
+// escapeBytes has been copied and modified from
+// https://cs.opensource.google/go/go/+/refs/tags/go1.17.1:src/net/url/url.go;l=284-338
+func (c *Codec) escapeBytes(s string) (string, []Span) {
+	spaceCount, hexCount := 0, 0
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if c.shouldEscape(b) {
+			if b == ' ' && c.spaceAsPlus {
+				spaceCount++
+			} else {
+				hexCount++
+			}
+		}
+	}
+
+	if spaceCount == 0 && hexCount == 0 {
+		return s, nil
+	}
+
+	var sb strings.Builder
+	spans := make([]Span, 0, spaceCount+hexCount)
+	sb.Grow(len(s) + 2*hexCount)
+
+	for i := 0; i < len(s); i++ {
+		switch b := s[i]; {
+		case b == ' ' && c.spaceAsPlus && c.shouldEscape(b):
+			spans = append(spans, Span{InStart: i, InEnd: i + 1, OutStart: sb.Len(), OutEnd: sb.Len() + 1})
+			sb.WriteByte('+')
+		case c.shouldEscape(b):
+			spans = append(spans, Span{InStart: i, InEnd: i + 1, OutStart: sb.Len(), OutEnd: sb.Len() + 3})
+			c.writeHexByte(&sb, b)
+		default:
+			sb.WriteByte(b)
+		}
+	}
+	return sb.String(), spans
+}
@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package codec
+
+import (
+	"bytes"
+	"testing"
+)
+
+// escapeTest is a value that is escaped into encoded, and unescaped back.
+type escapeTest struct {
+	mode    Encoding
+	decoded string
+	encoded string
+}
+
+// testEscapes checks each escapeTest with Escape and Unescape, and with a
+// Writer fed one byte at a time, so that escape sequences and UTF-8
+// sequences are split across calls to Write.
+func testEscapes(t *testing.T, tests []escapeTest) {
+	t.Helper()
+	for _, tc := range tests {
+		if got := Escape(tc.decoded, tc.mode); got != tc.encoded {
+			t.Errorf("Escape(%q, %s): want %q, got %q", tc.decoded, tc.mode, tc.encoded, got)
+		}
+		got, err := Unescape(tc.encoded, tc.mode)
+		if err != nil {
+			t.Errorf("Unescape(%q, %s): unexpected error: %v", tc.encoded, tc.mode, err)
+		} else if got != tc.decoded {
+			t.Errorf("Unescape(%q, %s): want %q, got %q", tc.encoded, tc.mode, tc.decoded, got)
+		}
+
+		var buf bytes.Buffer
+		if err := writeBytewise(NewEncoder(&buf, tc.mode), tc.decoded); err != nil {
+			t.Errorf("encoder %s, %q: unexpected error: %v", tc.mode, tc.decoded, err)
+		} else if buf.String() != tc.encoded {
+			t.Errorf("encoder %s, %q: want %q, got %q", tc.mode, tc.decoded, tc.encoded, buf.String())
+		}
+		buf.Reset()
+		if err := writeBytewise(NewDecoder(&buf, tc.mode), tc.encoded); err != nil {
+			t.Errorf("decoder %s, %q: unexpected error: %v", tc.mode, tc.encoded, err)
+		} else if buf.String() != tc.decoded {
+			t.Errorf("decoder %s, %q: want %q, got %q", tc.mode, tc.encoded, tc.decoded, buf.String())
+		}
+	}
+}
+
+// writeBytewise writes s to w one byte at a time, and then flushes it.
+func writeBytewise(w *Writer, s string) error {
+	for i := 0; i < len(s); i++ {
+		if _, err := w.Write([]byte{s[i]}); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func TestEscape(t *testing.T) {
+	testEscapes(t, []escapeTest{
+		{EncodePathSegment, "", ""},
+		{EncodePathSegment, "abc", "abc"},
+		{EncodePathSegment, "a b/c?d", "a%20b%2Fc%3Fd"},
+		{EncodePathSegment, "ü", "%C3%BC"},
+		{EncodePathSegment, "100%", "100%25"},
+		{EncodePath, "/a b/c?d", "/a%20b/c%3Fd"},
+		{EncodeQueryComponent, "a b&c=d", "a+b%26c%3Dd"},
+		{EncodeQueryComponent, "100% ✓", "100%25+%E2%9C%93"},
+		{EncodeUserPassword, "a:b@c", "a%3Ab%40c"},
+		{EncodeFragment, "a b!", "a%20b!"},
+	})
+}
+
+func TestUnescapeLowerHex(t *testing.T) {
+	got, err := Unescape("a%2fb%c3%bc", EncodePathSegment)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "a/bü"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestUnescapeErrors(t *testing.T) {
+	tests := []struct {
+		mode   Encoding
+		in     string
+		offset int
+	}{
+		{EncodePathSegment, "%", 0},
+		{EncodePathSegment, "ab%4", 2},
+		{EncodePathSegment, "ab%zz", 2},
+		{EncodeQueryComponent, "a=%g0", 2},
+	}
+	for _, tc := range tests {
+		_, err := Unescape(tc.in, tc.mode)
+		e, ok := err.(*Error)
+		if !ok {
+			t.Errorf("Unescape(%q, %s): want an *Error, got %v", tc.in, tc.mode, err)
+			continue
+		}
+		if e.Offset != tc.offset {
+			t.Errorf("Unescape(%q, %s): want offset %d, got %d", tc.in, tc.mode, tc.offset, e.Offset)
+		}
+	}
+}
+
+func TestWriterIncompleteEscape(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewDecoder(&buf, EncodePathSegment)
+	if err := writeBytewise(w, "ab%4"); err == nil {
+		t.Fatalf("want an error for the incomplete escape, got %q", buf.String())
+	}
+	buf.Reset()
+	if err := writeBytewise(w, "%41"); err != nil {
+		t.Fatalf("unexpected error after a failed value: %v", err)
+	}
+	if want := "A"; buf.String() != want {
+		t.Errorf("want %q, got %q", want, buf.String())
+	}
+}
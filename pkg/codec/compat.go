@@ -0,0 +1,223 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package codec
+
+import (
+	"net/url"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Characters left unescaped, on top of the alphanumerics, by well-known
+// functions in other languages' standard libraries.
+const (
+	// https://tc39.es/ecma262/#sec-encodeuri-uri
+	jsEncodeURI = "-_.!~*'();/?:@&=+$,#"
+	// https://tc39.es/ecma262/#sec-encodeuricomponent-uricomponent
+	jsEncodeURIComponent = "-_.!~*'()"
+	// https://tc39.es/ecma262/#sec-escape-string
+	jsEscape = "@*_+-./"
+	// https://www.php.net/manual/en/function.urlencode.php
+	phpURLEncode = "-_."
+	// https://www.php.net/manual/en/function.rawurlencode.php
+	phpRawURLEncode = "-_.~"
+	// https://docs.python.org/3/library/urllib.parse.html#urllib.parse.quote
+	pythonQuote = "_.-~/"
+	// https://docs.python.org/3/library/urllib.parse.html#urllib.parse.quote_plus
+	pythonQuotePlus = "_.-~"
+	// https://docs.oracle.com/javase/8/docs/api/java/net/URLEncoder.html
+	javaURLEncoder = ".-*_"
+	// https://learn.microsoft.com/en-us/dotnet/api/system.uri.escapedatastring
+	dotnetEscapeDataString = "-_.~"
+)
+
+// shouldEscapeCompat reports if the function emulated by mode escapes c.
+// The second return value is false if mode is not a compatibility encoding.
+func shouldEscapeCompat(c byte, mode Encoding) (escape bool, ok bool) {
+	var set string
+	switch mode {
+	case EncodeJSEncodeURI:
+		set = jsEncodeURI
+	case EncodeJSEncodeURIComponent:
+		set = jsEncodeURIComponent
+	case EncodeJSEscape:
+		set = jsEscape
+	case EncodePHPURLEncode:
+		set = phpURLEncode
+	case EncodePHPRawURLEncode:
+		set = phpRawURLEncode
+	case EncodePythonQuote:
+		set = pythonQuote
+	case EncodePythonQuotePlus:
+		set = pythonQuotePlus
+	case EncodeJavaURLEncoder:
+		set = javaURLEncoder
+	case EncodeDotnetEscapeDataString:
+		set = dotnetEscapeDataString
+	default:
+		return false, false
+	}
+	if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+		return false, true
+	}
+	return strings.IndexByte(set, c) == -1, true
+}
+
+// escapeUTF16 works like JavaScript's escape() function, which escapes
+// UTF-16 code units instead of UTF-8 bytes. Code units below 256 are escaped
+// as %XX, and the rest as %uXXXX. Bytes that are not valid UTF-8 are escaped
+// as if they were Latin-1.
+//
+// Unless atEOF is set, it stops before a trailing incomplete UTF-8 sequence
+// and returns the number of bytes of s it encoded.
+func (c *Codec) escapeUTF16(s string, atEOF bool) (string, []Span, int) {
+	var sb strings.Builder
+	var spans []Span
+	sb.Grow(len(s))
+	i := 0
+	for i < len(s) {
+		if !atEOF && !utf8.FullRuneInString(s[i:]) {
+			break
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			r = rune(s[i])
+		}
+		if r < utf8.RuneSelf && !c.shouldEscape(byte(r)) {
+			sb.WriteByte(byte(r))
+			i += size
+			continue
+		}
+		start := sb.Len()
+		if r < 256 {
+			c.writeHexByte(&sb, byte(r))
+		} else if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+			c.writeHexUnit(&sb, uint16(r1))
+			c.writeHexUnit(&sb, uint16(r2))
+		} else {
+			c.writeHexUnit(&sb, uint16(r))
+		}
+		spans = append(spans, Span{InStart: i, InEnd: i + size, OutStart: start, OutEnd: sb.Len()})
+		i += size
+	}
+	return sb.String(), spans, i
+}
+
+func (c *Codec) writeHexByte(sb *strings.Builder, b byte) {
+	sb.WriteByte('%')
+	sb.WriteByte(c.hex[b>>4])
+	sb.WriteByte(c.hex[b&15])
+}
+
+func (c *Codec) writeHexUnit(sb *strings.Builder, u uint16) {
+	sb.WriteString("%u")
+	sb.WriteByte(c.hex[u>>12])
+	sb.WriteByte(c.hex[u>>8&15])
+	sb.WriteByte(c.hex[u>>4&15])
+	sb.WriteByte(c.hex[u&15])
+}
+
+// unescapeUTF16 works like JavaScript's unescape() function, the inverse of
+// escapeUTF16. The decoded UTF-16 code units are written as UTF-8.
+//
+// Unless atEOF is set, it stops before a trailing incomplete escape sequence,
+// or a trailing escaped high surrogate, and returns the number of bytes of s
+// it decoded.
+func (c *Codec) unescapeUTF16(s string, atEOF bool) (string, []Span, int, error) {
+	var sb strings.Builder
+	var spans []Span
+	sb.Grow(len(s))
+	i := 0
+	for i < len(s) {
+		switch s[i] {
+		case '%':
+			u, size, err := unHexUnit(s[i:], atEOF)
+			if err != nil {
+				if !c.lenient {
+					return "", nil, 0, &Error{Offset: i, Err: err}
+				}
+				spans = append(spans, Span{InStart: i, InEnd: i + 1, OutStart: sb.Len(), OutEnd: sb.Len() + 1, Kind: SpanMalformed})
+				sb.WriteByte('%')
+				i++
+				continue
+			}
+			if size == 0 {
+				return sb.String(), spans, i, nil
+			}
+			r := rune(u)
+			if utf16.IsSurrogate(r) {
+				rest := s[i+size:]
+				if !atEOF && (rest == "" || rest == "%") {
+					return sb.String(), spans, i, nil
+				}
+				r = utf8.RuneError
+				if strings.HasPrefix(rest, "%u") {
+					u2, size2, err := unHexUnit(rest, atEOF)
+					if err == nil && size2 == 0 {
+						return sb.String(), spans, i, nil
+					}
+					if r2 := utf16.DecodeRune(rune(u), rune(u2)); err == nil && r2 != utf8.RuneError {
+						r = r2
+						size += size2
+					}
+				}
+			}
+			start := sb.Len()
+			sb.WriteRune(r)
+			spans = append(spans, Span{InStart: i, InEnd: i + size, OutStart: start, OutEnd: sb.Len()})
+			i += size
+		case '+':
+			if c.spaceAsPlus {
+				spans = append(spans, Span{InStart: i, InEnd: i + 1, OutStart: sb.Len(), OutEnd: sb.Len() + 1})
+				sb.WriteByte(' ')
+			} else {
+				sb.WriteByte('+')
+			}
+			i++
+		default:
+			sb.WriteByte(s[i])
+			i++
+		}
+	}
+	return sb.String(), spans, i, nil
+}
+
+// unHexUnit decodes a %XX or %uXXXX escape sequence at the start of s.
+// It returns a size of 0 if s is an incomplete escape sequence and atEOF is
+// not set.
+func unHexUnit(s string, atEOF bool) (uint16, int, error) {
+	size, digits := 3, 2
+	if len(s) > 1 && s[1] == 'u' {
+		size, digits = 6, 4
+	}
+	if len(s) < size {
+		if !atEOF {
+			return 0, 0, nil
+		}
+		return 0, 0, url.EscapeError(s)
+	}
+	var u uint16
+	for i := size - digits; i < size; i++ {
+		if !isHex(s[i]) {
+			return 0, 0, url.EscapeError(s[:size])
+		}
+		u = u<<4 | uint16(unHex(s[i]))
+	}
+	return u, size, nil
+}
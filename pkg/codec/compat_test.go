@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package codec
+
+import "testing"
+
+func TestEscapeCompat(t *testing.T) {
+	testEscapes(t, []escapeTest{
+		{EncodeJSEncodeURI, "a b/c?d=é#f", "a%20b/c?d=%C3%A9#f"},
+		{EncodeJSEncodeURIComponent, "a b/c?d=é", "a%20b%2Fc%3Fd%3D%C3%A9"},
+		{EncodeJSEncodeURIComponent, "!'()*~", "!'()*~"},
+		{EncodeJSEscape, "a b@é", "a%20b@%E9"},
+		{EncodeJSEscape, "€😀", "%u20AC%uD83D%uDE00"},
+		{EncodePHPURLEncode, "a b~", "a+b%7E"},
+		{EncodePHPRawURLEncode, "a b~", "a%20b~"},
+		{EncodePythonQuote, "a b/~", "a%20b/~"},
+		{EncodePythonQuotePlus, "a b/~", "a+b%2F~"},
+		{EncodeJavaURLEncoder, "a b*~", "a+b*%7E"},
+		{EncodeDotnetEscapeDataString, "a b!~", "a%20b%21~"},
+	})
+}
+
+func TestUnescapeUTF16Errors(t *testing.T) {
+	for _, in := range []string{"%u12", "%uZZZZ"} {
+		if _, err := Unescape(in, EncodeJSEscape); err == nil {
+			t.Errorf("Unescape(%q, %s): want an error", in, EncodeJSEscape)
+		}
+	}
+}
+
+func TestUnescapeLoneSurrogate(t *testing.T) {
+	got, err := Unescape("a%uD83Db", EncodeJSEscape)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "a\uFFFDb"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package codec
+
+// Encoding is a set of rules for which characters to escape, and how.
+type Encoding string
+
+const (
+	EncodePathSegment    Encoding = "path-segment"
+	EncodePath           Encoding = "path"
+	EncodeQueryComponent Encoding = "query"
+	EncodeHost           Encoding = "host"
+	EncodeZone           Encoding = "zone"
+	EncodeUserPassword   Encoding = "cred"
+	EncodeFragment       Encoding = "frag"
+	EncodeURL            Encoding = "url"
+
+	// Internationalized Resource Identifiers (IRI),
+	// https://www.rfc-editor.org/rfc/rfc3987
+	EncodeIRI Encoding = "iri"
+
+	// Percent-encode sets from the WHATWG URL Standard,
+	// https://url.spec.whatwg.org/#percent-encoded-bytes
+	EncodeWHATWGC0Control    Encoding = "whatwg-c0"
+	EncodeWHATWGFragment     Encoding = "whatwg-fragment"
+	EncodeWHATWGQuery        Encoding = "whatwg-query"
+	EncodeWHATWGSpecialQuery Encoding = "whatwg-special-query"
+	EncodeWHATWGPath         Encoding = "whatwg-path"
+	EncodeWHATWGUserinfo     Encoding = "whatwg-userinfo"
+	EncodeWHATWGComponent    Encoding = "whatwg-component"
+	EncodeWHATWGForm         Encoding = "whatwg-form"
+
+	// Compatibility with functions from other languages' standard libraries
+	EncodeJSEncodeURI            Encoding = "js-encodeuri"
+	EncodeJSEncodeURIComponent   Encoding = "js-encodeuricomponent"
+	EncodeJSEscape               Encoding = "js-escape"
+	EncodePHPURLEncode           Encoding = "php-urlencode"
+	EncodePHPRawURLEncode        Encoding = "php-rawurlencode"
+	EncodePythonQuote            Encoding = "python-quote"
+	EncodePythonQuotePlus        Encoding = "python-quote-plus"
+	EncodeJavaURLEncoder         Encoding = "java-urlencoder"
+	EncodeDotnetEscapeDataString Encoding = "dotnet-escapedatastring"
+)
+
+// Encodings are all the encodings, in the order they are listed in the help.
+var Encodings = []Encoding{
+	EncodePathSegment, EncodePath, EncodeQueryComponent, EncodeHost,
+	EncodeZone, EncodeUserPassword, EncodeFragment, EncodeURL, EncodeIRI,
+	EncodeWHATWGC0Control, EncodeWHATWGFragment, EncodeWHATWGQuery,
+	EncodeWHATWGSpecialQuery, EncodeWHATWGPath, EncodeWHATWGUserinfo,
+	EncodeWHATWGComponent, EncodeWHATWGForm,
+	EncodeJSEncodeURI, EncodeJSEncodeURIComponent, EncodeJSEscape,
+	EncodePHPURLEncode, EncodePHPRawURLEncode, EncodePythonQuote,
+	EncodePythonQuotePlus, EncodeJavaURLEncoder, EncodeDotnetEscapeDataString,
+}
+
+// Space decides how space is escaped. The zero value uses the default of
+// the encoding.
+type Space string
+
+const (
+	SpacePlus    Space = "plus"
+	SpacePercent Space = "percent"
+)
+
+// InvalidUTF8Policy decides what to do with decoded values that are not
+// valid UTF-8. The zero value leaves them as they are.
+type InvalidUTF8Policy string
+
+const (
+	// InvalidUTF8Fail fails decoding with an InvalidUTF8Error.
+	InvalidUTF8Fail InvalidUTF8Policy = "error"
+	// InvalidUTF8Replace replaces each invalid byte with U+FFFD.
+	InvalidUTF8Replace InvalidUTF8Policy = "replace"
+	// InvalidUTF8KeepEscaped keeps each invalid byte percent-encoded.
+	InvalidUTF8KeepEscaped InvalidUTF8Policy = "keep-escaped"
+)
@@ -0,0 +1,300 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package codec
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Explanation tells if and why a character of the input is escaped.
+type Explanation struct {
+	// Offset is the byte offset of the character in the input.
+	Offset int
+	// Char is the character, or a single byte if the input is not valid
+	// UTF-8 at Offset.
+	Char string
+	// Output is what the character is encoded into.
+	Output  string
+	Escaped bool
+	// Reason is the rule of the encoding that decided if the character is
+	// escaped, such as "§2.3 unreserved (alphanumeric)".
+	Reason string
+	// Component is the encoding used for the character's component of a
+	// whole URL, and is empty for other encodings.
+	Component Encoding
+}
+
+// compatFunctions are the names of the functions emulated by the
+// compatibility encodings.
+var compatFunctions = map[Encoding]string{
+	EncodeJSEncodeURI:            "JavaScript encodeURI()",
+	EncodeJSEncodeURIComponent:   "JavaScript encodeURIComponent()",
+	EncodeJSEscape:               "JavaScript escape()",
+	EncodePHPURLEncode:           "PHP urlencode()",
+	EncodePHPRawURLEncode:        "PHP rawurlencode()",
+	EncodePythonQuote:            "Python urllib.parse.quote()",
+	EncodePythonQuotePlus:        "Python urllib.parse.quote_plus()",
+	EncodeJavaURLEncoder:         "Java java.net.URLEncoder.encode()",
+	EncodeDotnetEscapeDataString: ".NET System.Uri.EscapeDataString()",
+}
+
+// Explain tells for each character of s if and why it is escaped, using the
+// same rules as Escape.
+func (c *Codec) Explain(s string) []Explanation {
+	if c.components != nil {
+		var explanations []Explanation
+		in := 0
+		for _, part := range splitURL(s, false) {
+			if part.mode == "" {
+				for i := 0; i < len(part.text); {
+					_, size := utf8.DecodeRuneInString(part.text[i:])
+					char := part.text[i : i+size]
+					explanations = append(explanations, Explanation{
+						Offset: in + i,
+						Char:   char,
+						Output: char,
+						Reason: urlPartReasons[part.kind],
+					})
+					i += size
+				}
+			} else {
+				for _, e := range c.components[part.mode].Explain(part.text) {
+					e.Offset += in
+					e.Component = part.mode
+					explanations = append(explanations, e)
+				}
+			}
+			in += len(part.text)
+		}
+		return explanations
+	}
+
+	explanations := c.explainChars(s)
+	if c.idna {
+		c.explainLabels(s, explanations)
+	}
+	return explanations
+}
+
+// urlPartReasons explain why the parts of a whole URL without their own
+// encoding are kept as they are.
+var urlPartReasons = map[urlPartKind]string{
+	urlDelimiter: "§3: delimiter between the components of the URL",
+	urlScheme:    "§3.1 scheme: kept as it is",
+	urlIPLiteral: "§3.2.2 host: IP literal, kept as it is",
+	urlPort:      "§3.2.3 port: kept as it is",
+}
+
+// explainChars explains each character of s.
+func (c *Codec) explainChars(s string) []Explanation {
+	var explanations []Explanation
+	for i := 0; i < len(s); {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		char := s[i : i+size]
+		escape, reason := c.explainByte(char[0])
+		e := Explanation{
+			Offset:  i,
+			Char:    char,
+			Output:  char,
+			Escaped: escape,
+			Reason:  reason,
+		}
+		if escape {
+			if c.utf16 {
+				e.Output, _, _ = c.escapeUTF16(char, true)
+			} else {
+				e.Output, _ = c.escapeBytes(char)
+			}
+		}
+		explanations = append(explanations, e)
+		i += size
+	}
+	return explanations
+}
+
+// explainLabels updates the explanations of the non-ASCII characters in the
+// domain name s, as they are converted into Punycode with their label.
+func (c *Codec) explainLabels(s string, explanations []Explanation) {
+	bidi := isBidiDomain(s)
+	in := 0
+	for _, label := range strings.SplitAfter(s, ".") {
+		name := strings.TrimSuffix(label, ".")
+		if !isASCII(name) {
+			ascii, err := toASCIILabel(name, bidi)
+			for i := range explanations {
+				e := &explanations[i]
+				if e.Offset < in || e.Offset >= in+len(name) || e.Char[0] < utf8.RuneSelf {
+					continue
+				}
+				if err != nil {
+					e.Reason = fmt.Sprintf("RFC 5891 §4: non-ASCII label can't be converted to Punycode, so it's escaped as UTF-8 bytes: %v", err)
+					continue
+				}
+				e.Output = ascii
+				e.Reason = "RFC 5891 §4: non-ASCII label is converted to Punycode"
+			}
+		}
+		in += len(label)
+	}
+}
+
+// explainByte reports if b is escaped, and why. For non-ASCII characters, b
+// is their first byte.
+func (c *Codec) explainByte(b byte) (bool, string) {
+	escape := c.shouldEscape(b)
+	if b == ' ' && escape && c.spaceAsPlus {
+		return true, "space is escaped as +, as in HTML forms"
+	}
+	switch {
+	case strings.IndexByte(c.opts.Unsafe, b) != -1:
+		return escape, "marked as unsafe"
+	case strings.IndexByte(c.opts.Safe, b) != -1:
+		return escape, "marked as safe"
+	case c.opts.Set != nil && escape:
+		return escape, "not in the custom character set"
+	case c.opts.Set != nil:
+		return escape, "in the custom character set"
+	}
+
+	if _, ok := shouldEscapeWHATWG(b, c.mode); ok {
+		return escape, explainWHATWG(b, c.mode, escape)
+	}
+	if name, ok := compatFunctions[c.mode]; ok {
+		switch {
+		case b >= utf8.RuneSelf && c.utf16:
+			return escape, name + " escapes non-ASCII as %uXXXX UTF-16 code units, or %XX below U+0100"
+		case b >= utf8.RuneSelf:
+			return escape, name + " escapes non-ASCII as UTF-8 bytes"
+		case isAlnum(b):
+			return escape, name + " leaves alphanumerics unescaped"
+		case escape:
+			return escape, fmt.Sprintf("%s escapes everything else, including %q", name, b)
+		}
+		return escape, fmt.Sprintf("%s leaves %q unescaped", name, b)
+	}
+	if c.mode == EncodeIRI {
+		switch {
+		case b >= utf8.RuneSelf:
+			return escape, "RFC 3987 §3.1: non-ASCII is escaped as UTF-8 bytes"
+		case escape:
+			return escape, fmt.Sprintf("RFC 3987 §3.1: %q is not allowed in URIs", b)
+		}
+		return escape, "RFC 3987 §3.1: allowed in URIs, so it's kept as it is"
+	}
+	_, reason := explainRFC3986(b, c.mode)
+	return escape, reason
+}
+
+func explainWHATWG(b byte, mode Encoding, escape bool) string {
+	set := strings.TrimPrefix(string(mode), "whatwg-")
+	if set == "c0" {
+		set = "C0 control"
+	}
+	switch {
+	case b >= utf8.RuneSelf:
+		return fmt.Sprintf("WHATWG %s percent-encode set: non-ASCII is escaped as UTF-8 bytes", set)
+	case isWHATWGC0Control(b):
+		return fmt.Sprintf("WHATWG %s percent-encode set: C0 controls are escaped", set)
+	case escape:
+		return fmt.Sprintf("WHATWG %s percent-encode set: %q is in the set", set, b)
+	}
+	return fmt.Sprintf("WHATWG %s percent-encode set: %q is not in the set", set, b)
+}
+
+// explainRFC3986 follows the same rules as shouldEscape, and uses its
+// comments as explanations. It also reports if c is escaped by the rule it
+// explains, which must agree with shouldEscape.
+func explainRFC3986(c byte, mode Encoding) (bool, string) {
+	if isAlnum(c) {
+		return false, "§2.3 unreserved (alphanumeric)"
+	}
+
+	if mode == EncodeHost || mode == EncodeZone {
+		switch c {
+		case '!', '$', '&', '\'', '(', ')', '*', '+', ',', ';', '=':
+			return false, "§3.2.2 host: sub-delims are allowed in reg-name"
+		case ':':
+			return false, "§3.2.2 host: ':' is allowed, as the host includes the :port"
+		case '[', ']':
+			return false, fmt.Sprintf("§3.2.2 host: %q is allowed, as the host includes [IPv6]:port", c)
+		case '<', '>', '"':
+			return false, fmt.Sprintf("§3.2.2 host: %q is allowed, as hosts can't use %%-encoding for ASCII bytes", c)
+		}
+	}
+
+	switch c {
+	case '-', '_', '.', '~':
+		return false, "§2.3 unreserved (mark)"
+
+	case '$', '&', '+', ',', '/', ':', ';', '=', '?', '@':
+		switch mode {
+		case EncodePath:
+			if c == '?' {
+				return true, "§3.3 path: '?' must be escaped, as it starts the query"
+			}
+			return false, "§3.3 path: reserved characters other than '?' are allowed"
+
+		case EncodePathSegment:
+			switch c {
+			case '?':
+				return true, "§3.3 path segment: '?' must be escaped, as it starts the query"
+			case '/', ';', ',':
+				return true, fmt.Sprintf("§3.3 path segment: %q must be escaped, as it is saved for the meaning of path segments", c)
+			}
+			return false, "§3.3 path segment: ':', '@', '&', '=', '+', and '$' are allowed"
+
+		case EncodeUserPassword:
+			switch c {
+			case ':':
+				return true, "§3.2.1 userinfo: ':' must be escaped, as it separates the username and password"
+			case '@', '/', '?':
+				return true, fmt.Sprintf("§3.2.1 userinfo: %q must be escaped, as it ends the userinfo", c)
+			}
+			return false, "§3.2.1 userinfo: ';', '&', '=', '+', '$', and ',' are allowed"
+
+		case EncodeQueryComponent:
+			return true, "§3.4 query: reserved characters must be escaped"
+
+		case EncodeFragment:
+			return false, "§4.1 fragment: reserved characters are allowed"
+		}
+		return true, fmt.Sprintf("§2.2 reserved: %q must be escaped", c)
+	}
+
+	switch {
+	case mode == EncodeFragment && strings.IndexByte("!()*", c) != -1:
+		return false, "§2.2 sub-delims: allowed in the fragment"
+	case strings.IndexByte("!'()*", c) != -1:
+		return true, "§2.2 sub-delims: always escaped outside of the fragment, like Go's net/url"
+	case c == '%':
+		return true, "§2.4 '%' must be escaped, as it starts escape sequences"
+	case strings.IndexByte("#[]", c) != -1:
+		return true, fmt.Sprintf("§2.2 gen-delims: %q must be escaped", c)
+	case c >= utf8.RuneSelf:
+		return true, "§2.5 non-ASCII: escaped as UTF-8 bytes"
+	case c < 0x20 || c == 0x7F:
+		return true, "§2: control characters are not allowed"
+	}
+	return true, fmt.Sprintf("§2: %q is not allowed", c)
+}
+
+func isAlnum(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
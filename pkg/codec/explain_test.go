@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package codec
+
+import "testing"
+
+func TestExplainRFC3986AgreesWithShouldEscape(t *testing.T) {
+	modes := []Encoding{
+		EncodePathSegment, EncodePath, EncodeQueryComponent, EncodeHost,
+		EncodeZone, EncodeUserPassword, EncodeFragment,
+	}
+	for _, mode := range modes {
+		for i := 0; i < 256; i++ {
+			b := byte(i)
+			escape, reason := explainRFC3986(b, mode)
+			if want := shouldEscape(b, mode); escape != want {
+				t.Errorf("%s: %q: explained as escaped=%t, but shouldEscape is %t: %s", mode, b, escape, want, reason)
+			}
+		}
+	}
+}
+
+func TestExplainAgreesWithEscape(t *testing.T) {
+	var s string
+	for i := 0x20; i < 0x7f; i++ {
+		s += string(rune(i))
+	}
+	s += "ü"
+	for _, mode := range Encodings {
+		if mode == EncodeURL {
+			continue
+		}
+		c := New(mode, Options{})
+		for _, e := range c.Explain(s) {
+			if e.Reason == "" {
+				t.Errorf("%s: %q: no reason", mode, e.Char)
+			}
+			if want := c.Escape(e.Char); e.Output != want && mode != EncodeHost {
+				t.Errorf("%s: %q: explained as %q, but escaped as %q", mode, e.Char, e.Output, want)
+			}
+			if escaped := e.Output != e.Char; escaped != e.Escaped {
+				t.Errorf("%s: %q: explained as escaped=%t, but output is %q", mode, e.Char, e.Escaped, e.Output)
+			}
+		}
+	}
+}
@@ -0,0 +1,349 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jilleJr/urlencode/pkg/punycode"
+)
+
+// acePrefix marks a label of a domain name as Punycode encoded.
+const acePrefix = "xn--"
+
+// maxLabelLen is the max length of a label in its ASCII form, per
+// https://www.rfc-editor.org/rfc/rfc1034#section-3.1
+const maxLabelLen = 63
+
+var (
+	errLabelHyphen    = errors.New("must not start or end with a hyphen")
+	errLabelHyphen34  = errors.New("must not have hyphens as both its third and fourth character")
+	errLabelMark      = errors.New("must not start with a combining mark")
+	errLabelChar      = errors.New("must only have letters, digits, combining marks, and hyphens")
+	errLabelLength    = fmt.Errorf("must be at most %d bytes long in its ASCII form", maxLabelLen)
+	errLabelASCII     = errors.New("must not encode only ASCII characters")
+	errLabelCanonical = errors.New("is not in its canonical form")
+	errLabelBidi      = errors.New("breaks the bidi rule of RFC 5893")
+)
+
+// LabelError is the Err of an Error when a label of an internationalized
+// domain name can't be converted to or from its Punycode form. It holds the
+// label as it was written in the input.
+type LabelError struct {
+	Label string
+	Err   error
+}
+
+func (e *LabelError) Error() string {
+	return fmt.Sprintf("invalid label %q: %v", e.Label, e.Err)
+}
+
+func (e *LabelError) Unwrap() error {
+	return e.Err
+}
+
+// escapeHost encodes each label of the domain name s. Labels with non-ASCII
+// characters are converted into their Punycode form, such as "münchen" into
+// "xn--mnchen-3ya", per https://www.rfc-editor.org/rfc/rfc5891#section-4.
+// All other labels are percent-encoded as usual. Unless atEOF is set, a
+// trailing label that may be incomplete is left for the next call.
+//
+// A trailing :port is percent-encoded as usual, as are IP literals such as
+// "[::1]", which have no labels. Labels that fail to convert are
+// percent-encoded instead, and the first such failure is returned as the
+// error.
+//
+// Labels are lowercased, but not otherwise mapped or normalized as described
+// by UTS #46, so the input should already be in Unicode normalization form C.
+func (c *Codec) escapeHost(s string, atEOF bool) (string, []Span, int, error) {
+	if !atEOF {
+		s = s[:strings.LastIndexByte(s, '.')+1]
+	}
+	host, port := s, ""
+	if atEOF {
+		host, port = splitHostPort(s)
+	}
+	if strings.HasPrefix(host, "[") {
+		t, spans := c.escapeBytes(s)
+		return t, spans, len(s), nil
+	}
+	bidi := isBidiDomain(host)
+	var sb strings.Builder
+	var spans []Span
+	var firstErr error
+	in := 0
+	for _, label := range strings.SplitAfter(host, ".") {
+		name := strings.TrimSuffix(label, ".")
+		if !isASCII(name) {
+			ascii, err := toASCIILabel(name, bidi)
+			if err == nil {
+				spans = append(spans, Span{InStart: in, InEnd: in + len(name), OutStart: sb.Len(), OutEnd: sb.Len() + len(ascii)})
+				sb.WriteString(ascii)
+				sb.WriteString(label[len(name):])
+				in += len(label)
+				continue
+			}
+			if firstErr == nil {
+				firstErr = &Error{Offset: in, Err: &LabelError{Label: name, Err: err}}
+			}
+		}
+		t, labelSpans := c.escapeBytes(label)
+		spans = appendShiftedSpans(spans, labelSpans, in, sb.Len())
+		sb.WriteString(t)
+		in += len(label)
+	}
+	t, portSpans := c.escapeBytes(port)
+	spans = appendShiftedSpans(spans, portSpans, in, sb.Len())
+	sb.WriteString(t)
+	return sb.String(), spans, len(s), firstErr
+}
+
+// unescapeHost decodes the domain name s, and converts each label in its
+// Punycode form back into Unicode. Unless atEOF is set, a trailing label
+// that may be incomplete is left for the next call. A trailing :port and IP
+// literals are only decoded as usual.
+func (c *Codec) unescapeHost(s string, atEOF bool) (string, []Span, int, error) {
+	if !atEOF {
+		s = s[:strings.LastIndexByte(s, '.')+1]
+	}
+	t, spans, n, err := c.unescapeBytes(s, true)
+	if err != nil || !strings.Contains(strings.ToLower(t), acePrefix) {
+		return t, spans, n, err
+	}
+
+	host, port := t, ""
+	if atEOF {
+		host, port = splitHostPort(t)
+	}
+	if strings.HasPrefix(host, "[") {
+		return t, spans, n, nil
+	}
+
+	// labelSpans are from t into the output.
+	var sb strings.Builder
+	var labelSpans []Span
+	in := 0
+	for _, label := range strings.SplitAfter(host, ".") {
+		name := strings.TrimSuffix(label, ".")
+		if len(name) < len(acePrefix) || !strings.EqualFold(name[:len(acePrefix)], acePrefix) {
+			sb.WriteString(label)
+			in += len(label)
+			continue
+		}
+		u, err := toUnicodeLabel(name)
+		if err != nil {
+			if !c.lenient {
+				start := inputOffset(spans, in)
+				end := inputOffset(spans, in+len(name))
+				return "", nil, 0, &Error{Offset: start, Err: &LabelError{Label: s[start:end], Err: err}}
+			}
+			labelSpans = append(labelSpans, Span{InStart: in, InEnd: in + len(name), OutStart: sb.Len(), OutEnd: sb.Len() + len(name), Kind: SpanMalformed})
+			u = name
+		} else {
+			labelSpans = append(labelSpans, Span{InStart: in, InEnd: in + len(name), OutStart: sb.Len(), OutEnd: sb.Len() + len(u)})
+		}
+		sb.WriteString(u)
+		sb.WriteString(label[len(name):])
+		in += len(label)
+	}
+	sb.WriteString(port)
+	return sb.String(), composeSpans(spans, labelSpans), n, nil
+}
+
+// inputOffset maps the offset pos in the output of spans back to the input.
+// The offset must not be inside a span.
+func inputOffset(spans []Span, pos int) int {
+	in := pos
+	for _, span := range spans {
+		if span.OutEnd > pos {
+			break
+		}
+		in += (span.InEnd - span.InStart) - (span.OutEnd - span.OutStart)
+	}
+	return in
+}
+
+// toASCIILabel converts a label with non-ASCII characters into its Punycode
+// form, with the "xn--" prefix.
+func toASCIILabel(label string, bidi bool) (string, error) {
+	label = strings.ToLower(label)
+	if err := checkLabel(label, bidi); err != nil {
+		return "", err
+	}
+	encoded, err := punycode.Encode(label)
+	if err != nil {
+		return "", err
+	}
+	ascii := acePrefix + encoded
+	if len(ascii) > maxLabelLen {
+		return "", errLabelLength
+	}
+	return ascii, nil
+}
+
+// toUnicodeLabel converts a label in its Punycode form, with the "xn--"
+// prefix, back into Unicode. Only labels that toASCIILabel would give back
+// unchanged, ignoring case, are accepted. The label is lowercased first, as
+// hosts are case-insensitive, so that Punycode's mixed-case annotation
+// doesn't end up in the result.
+func toUnicodeLabel(label string) (string, error) {
+	if len(label) > maxLabelLen {
+		return "", errLabelLength
+	}
+	u, err := punycode.Decode(strings.ToLower(label[len(acePrefix):]))
+	if err != nil {
+		return "", err
+	}
+	if isASCII(u) {
+		return "", errLabelASCII
+	}
+	ascii, err := toASCIILabel(u, isBidiDomain(u))
+	if err != nil {
+		return "", err
+	}
+	if ascii != strings.ToLower(label) {
+		return "", errLabelCanonical
+	}
+	return u, nil
+}
+
+// checkLabel validates a Unicode label, per
+// https://www.rfc-editor.org/rfc/rfc5891#section-4.2.3
+func checkLabel(label string, bidi bool) error {
+	if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+		return errLabelHyphen
+	}
+	if runes := []rune(label); len(runes) >= 4 && runes[2] == '-' && runes[3] == '-' {
+		return errLabelHyphen34
+	}
+	if r, _ := utf8.DecodeRuneInString(label); unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) {
+		return errLabelMark
+	}
+	for _, r := range label {
+		if r != '-' && !unicode.In(r, unicode.L, unicode.Nd, unicode.Mn, unicode.Me, unicode.Mc) {
+			return errLabelChar
+		}
+	}
+	if bidi && !checkBidi(label) {
+		return errLabelBidi
+	}
+	return nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// bidiClass is a simplified Unicode bidirectional class, as needed by the
+// bidi rule. The classes are approximated using the Unicode tables of the
+// standard library, which has no bidi class tables of its own.
+type bidiClass int
+
+const (
+	bidiL bidiClass = iota
+	// bidiR is both the R and AL classes
+	bidiR
+	bidiAN
+	bidiEN
+	bidiNSM
+	// bidiOther is any of the ES, CS, ET, ON, and BN classes
+	bidiOther
+)
+
+var rtlScripts = []*unicode.RangeTable{
+	unicode.Hebrew, unicode.Arabic, unicode.Syriac, unicode.Thaana,
+	unicode.Nko, unicode.Samaritan, unicode.Mandaic, unicode.Adlam,
+}
+
+func classOf(r rune) bidiClass {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return bidiNSM
+	case '0' <= r && r <= '9', 0x06F0 <= r && r <= 0x06F9:
+		return bidiEN
+	case 0x0600 <= r && r <= 0x0605, 0x0660 <= r && r <= 0x0669,
+		r == 0x066B, r == 0x066C, r == 0x06DD, 0x10E60 <= r && r <= 0x10E7E:
+		return bidiAN
+	case unicode.In(r, rtlScripts...):
+		return bidiR
+	case unicode.IsLetter(r), unicode.IsDigit(r), unicode.Is(unicode.Mc, r):
+		return bidiL
+	}
+	return bidiOther
+}
+
+// isBidiDomain reports if any label of the domain name s has right-to-left
+// characters, per https://www.rfc-editor.org/rfc/rfc5893#section-1.4
+func isBidiDomain(s string) bool {
+	for _, r := range s {
+		if c := classOf(r); c == bidiR || c == bidiAN {
+			return true
+		}
+	}
+	return false
+}
+
+// checkBidi reports if the label follows the bidi rule, per
+// https://www.rfc-editor.org/rfc/rfc5893#section-2
+func checkBidi(label string) bool {
+	var classes []bidiClass
+	for _, r := range label {
+		classes = append(classes, classOf(r))
+	}
+	if len(classes) == 0 {
+		return true
+	}
+	last := len(classes) - 1
+	for last > 0 && classes[last] == bidiNSM {
+		last--
+	}
+	var hasL, hasR, hasAN, hasEN bool
+	for _, c := range classes {
+		switch c {
+		case bidiL:
+			hasL = true
+		case bidiR:
+			hasR = true
+		case bidiAN:
+			hasAN = true
+		case bidiEN:
+			hasEN = true
+		}
+	}
+	switch classes[0] {
+	case bidiR:
+		// Rules 2, 3, and 4 for right-to-left labels
+		end := classes[last]
+		return !hasL && (end == bidiR || end == bidiEN || end == bidiAN) && !(hasEN && hasAN)
+	case bidiL:
+		// Rules 5 and 6 for left-to-right labels
+		end := classes[last]
+		return !hasR && !hasAN && (end == bidiL || end == bidiEN)
+	}
+	// Rule 1
+	return false
+}
@@ -0,0 +1,149 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEscapeHostRoundTrip(t *testing.T) {
+	testEscapes(t, []escapeTest{
+		{EncodeHost, "example.com", "example.com"},
+		{EncodeHost, "münchen.de:8080", "xn--mnchen-3ya.de:8080"},
+		{EncodeHost, "www.bücher.example.", "www.xn--bcher-kva.example."},
+		{EncodeHost, "[::1]:80", "[::1]:80"},
+	})
+}
+
+func TestEscapeHost(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"example.com", "example.com"},
+		{"münchen.de", "xn--mnchen-3ya.de"},
+		{"MÜNCHEN.de", "xn--mnchen-3ya.de"},
+		{"bücher.example:8080", "xn--bcher-kva.example:8080"},
+		{"[::1]:80", "[::1]:80"},
+	}
+	for _, tc := range tests {
+		got, _, err := EscapeSpans(tc.in, EncodeHost)
+		if err != nil {
+			t.Errorf("EscapeSpans(%q): unexpected error: %v", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("EscapeSpans(%q): want %q, got %q", tc.in, tc.want, got)
+		}
+	}
+}
+
+func TestEscapeHostRejectsLabel(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want error
+	}{
+		{"leading hyphen", "-ü.com", errLabelHyphen},
+		{"trailing hyphen", "ü-.com", errLabelHyphen},
+		{"hyphens at 3 and 4", "ab--ü.com", errLabelHyphen34},
+		{"leading mark", "́ü.com", errLabelMark},
+		{"too long", "ü" + strings.Repeat("a", 63) + ".com", errLabelLength},
+		{"bidi", "aא.com", errLabelBidi},
+	}
+	for _, tc := range tests {
+		_, _, err := EscapeSpans(tc.in, EncodeHost)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: EscapeSpans(%q): want error %v, got %v", tc.name, tc.in, tc.want, err)
+		}
+		var labelErr *LabelError
+		if !errors.As(err, &labelErr) {
+			t.Errorf("%s: EscapeSpans(%q): want a *LabelError, got %T", tc.name, tc.in, err)
+		}
+
+		var buf bytes.Buffer
+		w := NewEncoder(&buf, EncodeHost)
+		_, err = w.Write([]byte(tc.in))
+		if err == nil {
+			err = w.Flush()
+		}
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: Writer(%q): want error %v, got %v", tc.name, tc.in, tc.want, err)
+		}
+	}
+}
+
+func TestEscapeHostRejectedLabelFallback(t *testing.T) {
+	in := "a.-ü.com"
+	got, _, err := EscapeSpans(in, EncodeHost)
+	if err == nil {
+		t.Fatalf("EscapeSpans(%q): want an error", in)
+	}
+	if want := "a.-%C3%BC.com"; got != want {
+		t.Errorf("EscapeSpans(%q): want %q, got %q", in, want, got)
+	}
+	if e, ok := err.(*Error); !ok || e.Offset != 2 {
+		t.Errorf("EscapeSpans(%q): want an *Error at offset 2, got %#v", in, err)
+	}
+	if s := Escape(in, EncodeHost); s != got {
+		t.Errorf("Escape(%q): want %q, got %q", in, got, s)
+	}
+}
+
+func TestUnescapeHost(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"xn--mnchen-3ya.de", "münchen.de"},
+		{"XN--mnchen-3ya.de:8080", "münchen.de:8080"},
+		{"XN--MNCHEN-3YA.DE", "münchen.DE"},
+		{"xn--Bcher-kva.example", "bücher.example"},
+		{"[::1]:80", "[::1]:80"},
+	}
+	for _, tc := range tests {
+		got, err := Unescape(tc.in, EncodeHost)
+		if err != nil {
+			t.Errorf("Unescape(%q): unexpected error: %v", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("Unescape(%q): want %q, got %q", tc.in, tc.want, got)
+		}
+	}
+}
+
+func TestUnescapeHostRejectsLabel(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want error
+	}{
+		{"only ASCII", "xn--abc-.com", errLabelASCII},
+		{"leading hyphen", "xn---mnchen-lya.de", errLabelHyphen},
+		{"bidi", "xn--a-0hc.com", errLabelBidi},
+	}
+	for _, tc := range tests {
+		_, err := Unescape(tc.in, EncodeHost)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: Unescape(%q): want error %v, got %v", tc.name, tc.in, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package codec
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// shouldEscapeIRI reports if c must be escaped when converting an IRI into
+// a URI, per https://www.rfc-editor.org/rfc/rfc3987#section-3.1. Only
+// non-ASCII and the characters never allowed in a URI are escaped, so
+// reserved characters and existing escape sequences are kept as they are.
+//
+// The ok return value is false if mode is not the IRI encoding.
+func shouldEscapeIRI(c byte, mode Encoding) (escape, ok bool) {
+	if mode != EncodeIRI {
+		return false, false
+	}
+	if c <= 0x20 || c >= 0x7F {
+		return true, true
+	}
+	switch c {
+	case '"', '<', '>', '\\', '^', '`', '{', '|', '}':
+		return true, true
+	}
+	return false, true
+}
+
+// unescapeIRI converts a URI into an IRI, per
+// https://www.rfc-editor.org/rfc/rfc3987#section-3.2. Only escape sequences
+// of unreserved ASCII characters, and of printable non-ASCII characters in
+// valid UTF-8, are decoded. Everything else is kept escaped, so the meaning
+// of the URI doesn't change.
+func (c *Codec) unescapeIRI(s string, atEOF bool) (string, []Span, int, error) {
+	var t strings.Builder
+	var spans []Span
+	i := 0
+	for i < len(s) {
+		if s[i] != '%' {
+			t.WriteByte(s[i])
+			i++
+			continue
+		}
+		if i+2 >= len(s) && !atEOF {
+			break
+		}
+		if err := c.checkEscape(s, i); err != nil {
+			if !c.lenient {
+				return "", nil, 0, &Error{Offset: i, Err: err}
+			}
+			spans = append(spans, Span{InStart: i, InEnd: i + 1, OutStart: t.Len(), OutEnd: t.Len() + 1, Kind: SpanMalformed})
+			t.WriteByte('%')
+			i++
+			continue
+		}
+		b := unHex(s[i+1])<<4 | unHex(s[i+2])
+		if b < utf8.RuneSelf {
+			if isUnreserved(b) {
+				spans = append(spans, Span{InStart: i, InEnd: i + 3, OutStart: t.Len(), OutEnd: t.Len() + 1})
+				t.WriteByte(b)
+			} else {
+				t.WriteString(s[i : i+3])
+			}
+			i += 3
+			continue
+		}
+
+		// Collect the escape sequences of a whole UTF-8 encoded rune.
+		buf := []byte{b}
+		j := i + 3
+		for !utf8.FullRune(buf) && j+2 < len(s) && s[j] == '%' && isHex(s[j+1]) && isHex(s[j+2]) {
+			buf = append(buf, unHex(s[j+1])<<4|unHex(s[j+2]))
+			j += 3
+		}
+		if !utf8.FullRune(buf) && j+2 >= len(s) && !atEOF {
+			break
+		}
+		r, size := utf8.DecodeRune(buf)
+		if r == utf8.RuneError || size != len(buf) || !unicode.IsPrint(r) {
+			t.WriteString(s[i : i+3])
+			i += 3
+			continue
+		}
+		spans = append(spans, Span{InStart: i, InEnd: j, OutStart: t.Len(), OutEnd: t.Len() + size})
+		t.WriteRune(r)
+		i = j
+	}
+	return t.String(), spans, i, nil
+}
@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package codec
+
+// UnescapeLayers decodes s repeatedly using the rules of the given encoding,
+// such as "%253A" into "%3A" and then into ":". See Codec.UnescapeLayers.
+func UnescapeLayers(s string, mode Encoding, maxDepth int) ([]string, []Span, bool, error) {
+	return defaultCodec(mode).UnescapeLayers(s, maxDepth)
+}
+
+// UnescapeLayers decodes s repeatedly, until it no longer changes or maxDepth
+// layers have been decoded, and returns each decoded layer in order. The
+// last layer is the final result, and no layers are returned if s is not
+// encoded at all. The spans tell which parts of s were decoded into which
+// parts of the last layer. It also reports if the last layer would still
+// change when decoded once more, after stopping at maxDepth layers.
+//
+// Only an error in s itself is returned. A layer that fails to decode is
+// not encoded any further, such as "100%" decoded from "100%25", so it is
+// used as the final result.
+func (c *Codec) UnescapeLayers(s string, maxDepth int) (layers []string, spans []Span, more bool, err error) {
+	for {
+		t, tSpans, err := c.UnescapeSpans(s)
+		if err != nil {
+			if len(layers) == 0 {
+				return nil, nil, false, err
+			}
+			break
+		}
+		if t == s {
+			break
+		}
+		if len(layers) == maxDepth {
+			more = true
+			break
+		}
+		if len(layers) == 0 {
+			spans = tSpans
+		} else {
+			spans = composeSpans(spans, tSpans)
+		}
+		layers = append(layers, t)
+		s = t
+	}
+	return layers, spans, more, nil
+}
+
+// composeSpans combines the spans a, from s0 into s1, with the spans b, from
+// s1 into s2, into spans from s0 into s2. Spans that overlap in s1 are
+// merged, such as the "%" decoded from "%25" and the "%3A" it is part of.
+func composeSpans(a, b []Span) []Span {
+	// The In offsets of merged are offsets in s1, until they are mapped below.
+	var merged []Span
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		var next Span
+		if j >= len(b) || (i < len(a) && a[i].OutStart <= b[j].InStart) {
+			next = Span{InStart: a[i].OutStart, InEnd: a[i].OutEnd, Kind: a[i].Kind}
+			i++
+		} else {
+			next = Span{InStart: b[j].InStart, InEnd: b[j].InEnd, Kind: b[j].Kind}
+			j++
+		}
+		if n := len(merged); n > 0 && next.InStart < merged[n-1].InEnd {
+			last := &merged[n-1]
+			if next.InEnd > last.InEnd {
+				last.InEnd = next.InEnd
+			}
+			if next.Kind > last.Kind {
+				last.Kind = next.Kind
+			}
+			continue
+		}
+		merged = append(merged, next)
+	}
+
+	// Every span of a and b lies within a single merged span, so the parts
+	// between the merged spans only shift by the change in length of the
+	// spans before them.
+	var aShift, bShift int
+	i, j = 0, 0
+	shiftA := func(pos int) int {
+		for i < len(a) && a[i].OutEnd <= pos {
+			aShift += (a[i].InEnd - a[i].InStart) - (a[i].OutEnd - a[i].OutStart)
+			i++
+		}
+		return pos + aShift
+	}
+	shiftB := func(pos int) int {
+		for j < len(b) && b[j].InEnd <= pos {
+			bShift += (b[j].OutEnd - b[j].OutStart) - (b[j].InEnd - b[j].InStart)
+			j++
+		}
+		return pos + bShift
+	}
+	for k := range merged {
+		span := &merged[k]
+		start, end := span.InStart, span.InEnd
+		span.InStart, span.OutStart = shiftA(start), shiftB(start)
+		span.InEnd, span.OutEnd = shiftA(end), shiftB(end)
+	}
+	return merged
+}
@@ -0,0 +1,261 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package codec
+
+import (
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultPorts are the ports that are dropped when normalizing URLs of the
+// given schemes.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+	"ftp":   "21",
+}
+
+// Normalize converts the whole URL s into its canonical form, following
+// RFC 3986 §6.2.2 and §6.2.3, so that equivalent URLs become equal:
+//
+//   - scheme, host, and hex digits of escape sequences are made lowercase
+//     and uppercase respectively,
+//   - escaped unreserved characters, such as %7E, are decoded,
+//   - characters that the component does not allow are escaped, while
+//     reserved characters are left as-is,
+//   - non-ASCII letters of the host are lowercased too, also when they are
+//     percent-encoded,
+//   - dot-segments are removed from the path,
+//   - default and empty ports are dropped,
+//   - an empty path of a HTTP URL becomes /.
+//
+// A % sign that doesn't start an escape sequence is reported as an *Error,
+// as escaping it would change the URL.
+func Normalize(s string) (string, error) {
+	parts := splitURL(s, true)
+	var scheme string
+	hasAuthority := false
+	for _, part := range parts {
+		switch part.kind {
+		case urlScheme:
+			scheme = lowerASCII(part.text)
+		case urlHost, urlIPLiteral:
+			hasAuthority = true
+		}
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(s))
+	hasPath := false
+	in := 0
+	for _, part := range parts {
+		var t string
+		var err error
+		switch part.kind {
+		case urlScheme, urlIPLiteral:
+			sb.WriteString(lowerASCII(part.text))
+		case urlHost:
+			t, err = normalizeHost(part.text, part.mode)
+			sb.WriteString(t)
+		case urlPort:
+			if port := part.text[1:]; port != "" && port != defaultPorts[scheme] {
+				sb.WriteString(part.text)
+			}
+		case urlPath:
+			hasPath = true
+			t, err = normalizePercent(part.text, part.mode)
+			sb.WriteString(removeDotSegments(t))
+		case urlDelimiter:
+			if !hasPath && hasAuthority && (part.text == "?" || part.text == "#") {
+				sb.WriteString(emptyPath(scheme))
+				hasPath = true
+			}
+			sb.WriteString(part.text)
+		default:
+			t, err = normalizePercent(part.text, part.mode)
+			sb.WriteString(t)
+		}
+		if err != nil {
+			return "", shiftError(err, in)
+		}
+		in += len(part.text)
+	}
+	if !hasPath && hasAuthority {
+		sb.WriteString(emptyPath(scheme))
+	}
+	return sb.String(), nil
+}
+
+// normalizeHost lowercases the host s, including its non-ASCII letters, and
+// then normalizes its escape sequences. Escaped non-ASCII characters are
+// decoded before they are lowercased, so that "M%C3%9CNCHEN" becomes the same
+// as "münchen", which is "m%C3%BCnchen".
+func normalizeHost(s string, mode Encoding) (string, error) {
+	if err := checkPercent(s); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' {
+			if v := unHex(s[i+1])<<4 | unHex(s[i+2]); v >= 0x80 {
+				sb.WriteByte(v)
+				i += 2
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	host := sb.String()
+	// strings.ToLower would replace invalid UTF-8 with U+FFFD
+	if utf8.ValidString(host) {
+		host = strings.ToLower(host)
+	} else {
+		host = lowerASCII(host)
+	}
+	return normalizePercent(host, mode)
+}
+
+// checkPercent reports a % sign in s that doesn't start an escape sequence.
+func checkPercent(s string) error {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && (i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2])) {
+			end := i + 3
+			if end > len(s) {
+				end = len(s)
+			}
+			return &Error{Offset: i, Err: url.EscapeError(s[i:end])}
+		}
+	}
+	return nil
+}
+
+// emptyPath returns what an empty path is normalized into, per RFC 3986
+// §6.2.3.
+func emptyPath(scheme string) string {
+	switch scheme {
+	case "http", "https", "ws", "wss":
+		return "/"
+	}
+	return ""
+}
+
+// normalizePercent uppercases the hex digits of escape sequences, decodes
+// escaped unreserved characters, and escapes characters that are neither
+// allowed by the encoding nor reserved.
+func normalizePercent(s string, mode Encoding) (string, error) {
+	if err := checkPercent(s); err != nil {
+		return "", err
+	}
+	c := defaultCodec(mode)
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		switch {
+		case b == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			v := unHex(s[i+1])<<4 | unHex(s[i+2])
+			if isUnreserved(v) {
+				sb.WriteByte(v)
+			} else {
+				c.writeHexByte(&sb, v)
+			}
+			i += 2
+		case c.shouldEscape(b) && !isReserved(b):
+			c.writeHexByte(&sb, b)
+		default:
+			sb.WriteByte(b)
+		}
+	}
+	return sb.String(), nil
+}
+
+// lowerASCII lowercases only the ASCII letters of s, leaving any other bytes
+// untouched.
+func lowerASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// isUnreserved reports if c is in RFC 3986 §2.3 unreserved characters.
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// isReserved reports if c is in RFC 3986 §2.2 reserved characters.
+func isReserved(c byte) bool {
+	return strings.IndexByte(charClasses["reserved"], c) != -1
+}
+
+// removeDotSegments follows the algorithm of RFC 3986 §5.2.4.
+func removeDotSegments(path string) string {
+	var out []string
+	in := path
+	for in != "" {
+		switch {
+		// A. Remove prefix "../" or "./"
+		case strings.HasPrefix(in, "../"):
+			in = in[3:]
+		case strings.HasPrefix(in, "./"):
+			in = in[2:]
+		// B. Replace prefix "/./" or "/." with "/"
+		case strings.HasPrefix(in, "/./"):
+			in = in[2:]
+		case in == "/.":
+			in = "/"
+		// C. Replace prefix "/../" or "/.." with "/", and remove the last
+		// segment from the output
+		case strings.HasPrefix(in, "/../"):
+			in = in[3:]
+			out = dropLastSegment(out)
+		case in == "/..":
+			in = "/"
+			out = dropLastSegment(out)
+		// D. Remove a lone "." or ".."
+		case in == "." || in == "..":
+			in = ""
+		// E. Move the first segment, including its initial "/", to the output
+		default:
+			i := strings.IndexByte(in[1:], '/')
+			if i == -1 {
+				out = append(out, in)
+				in = ""
+			} else {
+				out = append(out, in[:i+1])
+				in = in[i+1:]
+			}
+		}
+	}
+	return strings.Join(out, "")
+}
+
+func dropLastSegment(segments []string) []string {
+	if len(segments) == 0 {
+		return segments
+	}
+	return segments[:len(segments)-1]
+}
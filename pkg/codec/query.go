@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package codec
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// QueryPair is a key and value of a query string. HasValue is false for
+// keys without an = sign, such as "flag" in "flag&a=1".
+type QueryPair struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	HasValue bool   `json:"-"`
+}
+
+// MarshalJSON encodes the pair as {"key": ..., "value": ...}, where the
+// value is null for keys without an = sign.
+func (p QueryPair) MarshalJSON() ([]byte, error) {
+	var value *string
+	if p.HasValue {
+		value = &p.Value
+	}
+	return json.Marshal(struct {
+		Key   string  `json:"key"`
+		Value *string `json:"value"`
+	}{p.Key, value})
+}
+
+// ParseQuery decodes a query string, such as "a=1&b=hello+world&a=2", into
+// its key and value pairs. Unlike url.ParseQuery, it keeps the order and
+// any repeated keys.
+func ParseQuery(query string) ([]QueryPair, error) {
+	var pairs []QueryPair
+	if query == "" {
+		return pairs, nil
+	}
+	offset := 0
+	for _, field := range strings.Split(query, "&") {
+		key, value, hasValue := strings.Cut(field, "=")
+		k, err := Unescape(key, EncodeQueryComponent)
+		if err != nil {
+			return nil, shiftError(err, offset)
+		}
+		v, err := Unescape(value, EncodeQueryComponent)
+		if err != nil {
+			return nil, shiftError(err, offset+len(key)+1)
+		}
+		pairs = append(pairs, QueryPair{Key: k, Value: v, HasValue: hasValue})
+		offset += len(field) + 1
+	}
+	return pairs, nil
+}
+
+// BuildQuery encodes key and value pairs into a query string, using the
+// rules of the query encoding.
+func BuildQuery(pairs []QueryPair) string {
+	var sb strings.Builder
+	for i, pair := range pairs {
+		if i > 0 {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(Escape(pair.Key, EncodeQueryComponent))
+		if pair.HasValue {
+			sb.WriteByte('=')
+			sb.WriteString(Escape(pair.Value, EncodeQueryComponent))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package codec
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// InvalidUTF8Error is the Err of an Error when a decoded value is not valid
+// UTF-8. It holds the part of the input that decoded into the invalid byte.
+type InvalidUTF8Error string
+
+func (e InvalidUTF8Error) Error() string {
+	return fmt.Sprintf("invalid UTF-8 %q", string(e))
+}
+
+// checkUTF8 applies the invalid UTF-8 policy of the codec to t, decoded from
+// the first n bytes of s. Unless atEOF is set, a trailing incomplete UTF-8
+// sequence is left for the next call, by decoding fewer bytes of s.
+func (c *Codec) checkUTF8(s, t string, spans []Span, n int, atEOF bool) (string, []Span, int, error) {
+	if !atEOF {
+		t, spans, n = trimIncompleteRune(t, spans, n)
+	}
+	if utf8.ValidString(t) {
+		return t, spans, n, nil
+	}
+
+	var invalid []int
+	for i := 0; i < len(t); {
+		r, size := utf8.DecodeRuneInString(t[i:])
+		if r == utf8.RuneError && size == 1 {
+			invalid = append(invalid, i)
+		}
+		i += size
+	}
+
+	var sb strings.Builder
+	var out []Span
+	// shift is the input length minus the output length of the spans so far
+	shift := 0
+	last := 0
+	for len(invalid) > 0 {
+		p := invalid[0]
+		for len(spans) > 0 && spans[0].OutEnd <= p {
+			out = append(out, moveSpan(spans[0], sb.Len()-last))
+			shift += (spans[0].InEnd - spans[0].InStart) - (spans[0].OutEnd - spans[0].OutStart)
+			spans = spans[1:]
+		}
+		// The invalid byte is either decoded by a span, or copied as-is from
+		// the input.
+		span := Span{InStart: p + shift, InEnd: p + shift + 1, OutStart: p, OutEnd: p + 1}
+		if len(spans) > 0 && spans[0].OutStart <= p {
+			span = spans[0]
+			shift += (span.InEnd - span.InStart) - (span.OutEnd - span.OutStart)
+			spans = spans[1:]
+		}
+		if c.invalidUTF8 == InvalidUTF8Fail {
+			return "", nil, 0, &Error{
+				Offset: span.InStart,
+				Err:    InvalidUTF8Error(s[span.InStart:span.InEnd]),
+			}
+		}
+		sb.WriteString(t[last:span.OutStart])
+		outStart := sb.Len()
+		for i := span.OutStart; i < span.OutEnd; i++ {
+			if len(invalid) == 0 || invalid[0] != i {
+				sb.WriteByte(t[i])
+				continue
+			}
+			invalid = invalid[1:]
+			if c.invalidUTF8 == InvalidUTF8KeepEscaped {
+				c.writeHexByte(&sb, t[i])
+			} else {
+				sb.WriteRune(utf8.RuneError)
+			}
+		}
+		out = append(out, Span{
+			InStart:  span.InStart,
+			InEnd:    span.InEnd,
+			OutStart: outStart,
+			OutEnd:   sb.Len(),
+			Kind:     SpanInvalidUTF8,
+		})
+		last = span.OutEnd
+	}
+	for _, span := range spans {
+		out = append(out, moveSpan(span, sb.Len()-last))
+	}
+	sb.WriteString(t[last:])
+	return sb.String(), out, n, nil
+}
+
+// trimIncompleteRune removes a trailing incomplete UTF-8 sequence from t,
+// decoded from the first n bytes of the input, together with its spans, and
+// returns how many bytes of the input are left decoded.
+func trimIncompleteRune(t string, spans []Span, n int) (string, []Span, int) {
+	start := len(t)
+	for i := len(t) - 1; i >= 0 && i >= len(t)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(t[i]) {
+			start = i
+			break
+		}
+	}
+	if start == len(t) || utf8.FullRuneInString(t[start:]) {
+		return t, spans, n
+	}
+	// Everything in t after start is either decoded by a span, or copied
+	// as-is from the input.
+	cut := n - (len(t) - start)
+	for i := len(spans) - 1; i >= 0 && spans[i].OutEnd > start; i-- {
+		if spans[i].OutStart < start {
+			start = spans[i].OutStart
+		}
+		cut = spans[i].InStart - (spans[i].OutStart - start)
+		spans = spans[:i]
+	}
+	return t[:start], spans, cut
+}
+
+// moveSpan moves the output of span by the given offset.
+func moveSpan(span Span, by int) Span {
+	span.OutStart += by
+	span.OutEnd += by
+	return span
+}
@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package codec
+
+import "strings"
+
+// The percent-encode sets of the WHATWG URL Standard,
+// https://url.spec.whatwg.org/#percent-encoded-bytes
+//
+// Each set is defined as the set before it plus a few more characters, and
+// all of them include the C0 control percent-encode set.
+const (
+	whatwgFragment     = " \"<>`"
+	whatwgQuery        = " \"#<>"
+	whatwgSpecialQuery = whatwgQuery + "'"
+	whatwgPath         = whatwgQuery + "?^`{}"
+	whatwgUserinfo     = whatwgPath + "/:;=@[\\]^|"
+	whatwgComponent    = whatwgUserinfo + "$%&+,"
+	whatwgForm         = whatwgComponent + "!'()~"
+)
+
+// isWHATWGC0Control reports if c is in the C0 control percent-encode set,
+// which are the C0 controls and all code points greater than U+007E (~).
+// As we work on UTF-8 bytes, that includes all bytes of non-ASCII runes.
+func isWHATWGC0Control(c byte) bool {
+	return c < 0x20 || c > 0x7E
+}
+
+// shouldEscapeWHATWG reports if c is in the WHATWG percent-encode set of
+// the mode. The second return value is false if mode is not a WHATWG
+// encoding.
+func shouldEscapeWHATWG(c byte, mode Encoding) (escape bool, ok bool) {
+	var set string
+	switch mode {
+	case EncodeWHATWGC0Control:
+	case EncodeWHATWGFragment:
+		set = whatwgFragment
+	case EncodeWHATWGQuery:
+		set = whatwgQuery
+	case EncodeWHATWGSpecialQuery:
+		set = whatwgSpecialQuery
+	case EncodeWHATWGPath:
+		set = whatwgPath
+	case EncodeWHATWGUserinfo:
+		set = whatwgUserinfo
+	case EncodeWHATWGComponent:
+		set = whatwgComponent
+	case EncodeWHATWGForm:
+		set = whatwgForm
+	default:
+		return false, false
+	}
+	return isWHATWGC0Control(c) || strings.IndexByte(set, c) != -1, true
+}
@@ -0,0 +1,196 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package codec
+
+import "io"
+
+// SpanWriter can be implemented by the writer given to NewEncoder or
+// NewDecoder to also receive which parts of the output were changed.
+// The output offsets of the spans are relative to s, and the input offsets
+// are relative to the start of the current value.
+type SpanWriter interface {
+	WriteSpans(s string, spans []Span) (int, error)
+}
+
+// LayerWriter can be implemented by the writer given to NewRecursiveDecoder
+// to also receive the decoded layers of each value, before the final result
+// is written, and if the value stopped at maxDepth while still encoded. See
+// Codec.UnescapeLayers.
+type LayerWriter interface {
+	WriteLayers(layers []string, more bool) error
+}
+
+// Writer is a streaming encoder or decoder. Everything written to it is
+// encoded or decoded and then written to the underlying writer, using
+// constant memory no matter how large the input is. The exceptions are the
+// whole URL encoding, which needs to see the entire value before it can be
+// split into its components, recursive decoding, and the host encoding,
+// which needs to see each label of a domain name up to its dot.
+type Writer struct {
+	w        io.Writer
+	codec    *Codec
+	decode   bool
+	maxDepth int
+	pending  []byte
+	// offset is the number of input bytes processed since the last Flush
+	offset int
+}
+
+// NewEncoder returns a Writer that percent-encodes everything written to it
+// into w.
+func NewEncoder(w io.Writer, mode Encoding) *Writer {
+	return defaultCodec(mode).NewEncoder(w)
+}
+
+// NewDecoder returns a Writer that decodes everything written to it into w.
+// Escape sequences may be split across multiple calls to Write.
+func NewDecoder(w io.Writer, mode Encoding) *Writer {
+	return defaultCodec(mode).NewDecoder(w)
+}
+
+// NewRecursiveDecoder returns a Writer that decodes each value repeatedly
+// into w, until it no longer changes or maxDepth layers have been decoded.
+func NewRecursiveDecoder(w io.Writer, mode Encoding, maxDepth int) *Writer {
+	return defaultCodec(mode).NewRecursiveDecoder(w, maxDepth)
+}
+
+// NewEncoder returns a Writer that percent-encodes everything written to it
+// into w.
+func (c *Codec) NewEncoder(w io.Writer) *Writer {
+	return &Writer{w: w, codec: c}
+}
+
+// NewDecoder returns a Writer that decodes everything written to it into w.
+// Escape sequences may be split across multiple calls to Write.
+func (c *Codec) NewDecoder(w io.Writer) *Writer {
+	return &Writer{w: w, codec: c, decode: true}
+}
+
+// NewRecursiveDecoder returns a Writer that decodes each value repeatedly
+// into w, until it no longer changes or maxDepth layers have been decoded.
+// Each value is kept in memory until Flush.
+func (c *Codec) NewRecursiveDecoder(w io.Writer, maxDepth int) *Writer {
+	return &Writer{w: w, codec: c, decode: true, maxDepth: maxDepth}
+}
+
+// Write encodes or decodes p and writes the result to the underlying writer.
+// A trailing incomplete escape sequence, or an incomplete UTF-8 sequence when
+// encoding UTF-16, is kept until the next call to Write or Flush. Encoding
+// fails for the same labels as Codec.EscapeSpans.
+func (w *Writer) Write(p []byte) (int, error) {
+	if err := w.write(p, false); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush ends the current value, and reports an error if it ended with an
+// incomplete escape sequence. The Writer can be used for a new value
+// afterwards.
+//
+// The offsets of errors returned by Write and Flush are relative to the start
+// of the current value. After an error, the rest of the value is treated as
+// a new value.
+func (w *Writer) Flush() error {
+	return w.write(nil, true)
+}
+
+// Close is the same as Flush. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	return w.Flush()
+}
+
+func (w *Writer) write(p []byte, atEOF bool) error {
+	if w.maxDepth > 0 {
+		return w.writeLayers(p, atEOF)
+	}
+	var s string
+	if len(w.pending) > 0 {
+		s = string(append(w.pending, p...))
+		w.pending = w.pending[:0]
+	} else {
+		s = string(p)
+	}
+	if s == "" {
+		if atEOF {
+			w.offset = 0
+		}
+		return nil
+	}
+
+	var t string
+	var spans []Span
+	var n int
+	var err error
+	if w.decode {
+		t, spans, n, err = w.codec.unescape(s, atEOF)
+	} else {
+		t, spans, n, err = w.codec.escape(s, atEOF)
+	}
+	if err != nil {
+		err = shiftError(err, w.offset)
+		w.offset = 0
+		return err
+	}
+	for i := range spans {
+		spans[i].InStart += w.offset
+		spans[i].InEnd += w.offset
+	}
+	w.pending = append(w.pending, s[n:]...)
+	if atEOF {
+		w.offset = 0
+	} else {
+		w.offset += n
+	}
+	return w.emit(t, spans)
+}
+
+func (w *Writer) writeLayers(p []byte, atEOF bool) error {
+	w.pending = append(w.pending, p...)
+	if !atEOF || len(w.pending) == 0 {
+		return nil
+	}
+	s := string(w.pending)
+	w.pending = w.pending[:0]
+	layers, spans, more, err := w.codec.UnescapeLayers(s, w.maxDepth)
+	if err != nil {
+		return err
+	}
+	if lw, ok := w.w.(LayerWriter); ok {
+		if err := lw.WriteLayers(layers, more); err != nil {
+			return err
+		}
+	}
+	if len(layers) > 0 {
+		s = layers[len(layers)-1]
+	}
+	return w.emit(s, spans)
+}
+
+func (w *Writer) emit(s string, spans []Span) error {
+	if s == "" {
+		return nil
+	}
+	var err error
+	if sw, ok := w.w.(SpanWriter); ok {
+		_, err = sw.WriteSpans(s, spans)
+	} else {
+		_, err = io.WriteString(w.w, s)
+	}
+	return err
+}
@@ -19,20 +19,50 @@ package flagtype
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/jilleJr/urlencode/pkg/codec"
 	"github.com/spf13/cobra"
 )
 
-type Encoding string
+// Encoding is a codec.Encoding that can be set from a flag.
+type Encoding codec.Encoding
 
 const (
-	EncodePathSegment    Encoding = "path-segment"
-	EncodePath           Encoding = "path"
-	EncodeQueryComponent Encoding = "query"
-	EncodeHost           Encoding = "host"
-	EncodeZone           Encoding = "zone"
-	EncodeUserPassword   Encoding = "cred"
-	EncodeFragment       Encoding = "frag"
+	EncodePathSegment    = Encoding(codec.EncodePathSegment)
+	EncodePath           = Encoding(codec.EncodePath)
+	EncodeQueryComponent = Encoding(codec.EncodeQueryComponent)
+	EncodeHost           = Encoding(codec.EncodeHost)
+	EncodeZone           = Encoding(codec.EncodeZone)
+	EncodeUserPassword   = Encoding(codec.EncodeUserPassword)
+	EncodeFragment       = Encoding(codec.EncodeFragment)
+	EncodeURL            = Encoding(codec.EncodeURL)
+
+	// Internationalized Resource Identifiers (IRI),
+	// https://www.rfc-editor.org/rfc/rfc3987
+	EncodeIRI = Encoding(codec.EncodeIRI)
+
+	// Percent-encode sets from the WHATWG URL Standard,
+	// https://url.spec.whatwg.org/#percent-encoded-bytes
+	EncodeWHATWGC0Control    = Encoding(codec.EncodeWHATWGC0Control)
+	EncodeWHATWGFragment     = Encoding(codec.EncodeWHATWGFragment)
+	EncodeWHATWGQuery        = Encoding(codec.EncodeWHATWGQuery)
+	EncodeWHATWGSpecialQuery = Encoding(codec.EncodeWHATWGSpecialQuery)
+	EncodeWHATWGPath         = Encoding(codec.EncodeWHATWGPath)
+	EncodeWHATWGUserinfo     = Encoding(codec.EncodeWHATWGUserinfo)
+	EncodeWHATWGComponent    = Encoding(codec.EncodeWHATWGComponent)
+	EncodeWHATWGForm         = Encoding(codec.EncodeWHATWGForm)
+
+	// Compatibility with functions from other languages' standard libraries
+	EncodeJSEncodeURI            = Encoding(codec.EncodeJSEncodeURI)
+	EncodeJSEncodeURIComponent   = Encoding(codec.EncodeJSEncodeURIComponent)
+	EncodeJSEscape               = Encoding(codec.EncodeJSEscape)
+	EncodePHPURLEncode           = Encoding(codec.EncodePHPURLEncode)
+	EncodePHPRawURLEncode        = Encoding(codec.EncodePHPRawURLEncode)
+	EncodePythonQuote            = Encoding(codec.EncodePythonQuote)
+	EncodePythonQuotePlus        = Encoding(codec.EncodePythonQuotePlus)
+	EncodeJavaURLEncoder         = Encoding(codec.EncodeJavaURLEncoder)
+	EncodeDotnetEscapeDataString = Encoding(codec.EncodeDotnetEscapeDataString)
 )
 
 // String is used both by fmt.Print and by Cobra in help text
@@ -42,7 +72,7 @@ func (e *Encoding) String() string {
 
 // Set must have pointer receiver so it doesn't change the value of a copy
 func (e *Encoding) Set(v string) error {
-	switch v {
+	switch strings.ToLower(v) {
 	case "s", "path-segment":
 		*e = EncodePathSegment
 	case "p", "path":
@@ -57,6 +87,44 @@ func (e *Encoding) Set(v string) error {
 		*e = EncodeUserPassword
 	case "f", "frag":
 		*e = EncodeFragment
+	case "u", "url":
+		*e = EncodeURL
+	case "iri":
+		*e = EncodeIRI
+	case "whatwg-c0":
+		*e = EncodeWHATWGC0Control
+	case "whatwg-fragment":
+		*e = EncodeWHATWGFragment
+	case "whatwg-query":
+		*e = EncodeWHATWGQuery
+	case "whatwg-special-query":
+		*e = EncodeWHATWGSpecialQuery
+	case "whatwg-path":
+		*e = EncodeWHATWGPath
+	case "whatwg-userinfo":
+		*e = EncodeWHATWGUserinfo
+	case "whatwg-component":
+		*e = EncodeWHATWGComponent
+	case "whatwg-form":
+		*e = EncodeWHATWGForm
+	case "js-encodeuri":
+		*e = EncodeJSEncodeURI
+	case "js-encodeuricomponent":
+		*e = EncodeJSEncodeURIComponent
+	case "js-escape":
+		*e = EncodeJSEscape
+	case "php-urlencode":
+		*e = EncodePHPURLEncode
+	case "php-rawurlencode":
+		*e = EncodePHPRawURLEncode
+	case "python-quote":
+		*e = EncodePythonQuote
+	case "python-quote-plus":
+		*e = EncodePythonQuotePlus
+	case "java-urlencoder":
+		*e = EncodeJavaURLEncoder
+	case "dotnet-escapedatastring":
+		*e = EncodeDotnetEscapeDataString
 	default:
 		return fmt.Errorf("invalid encoding: %q", v)
 	}
@@ -76,13 +144,33 @@ func CompleteEncoding(cmd *cobra.Command, args []string, toComplete string) ([]s
 		"p\tAll path segments, including the slashes /",
 		"query\tQuery parameter (key or value), e.g ?key=value",
 		"q\tQuery parameter (key or value), e.g ?key=value",
-		"host\tHostname (FQDN)",
-		"h\tHostname (FQDN)",
+		"host\tHostname (FQDN), with Punycode for non-ASCII labels",
+		"h\tHostname (FQDN), with Punycode for non-ASCII labels",
 		"cred\tCredentials (username:password@)",
 		"c\tCredentials (username:password@)",
 		"frag\tFragment parameter, everything past the hash #",
 		"f\tFragment parameter, everything past the hash #",
 		"zone\tIPv6 zone parameter",
 		"z\tIPv6 zone parameter",
+		"url\tWhole URL, each component with its own encoding",
+		"u\tWhole URL, each component with its own encoding",
+		"iri\tIRI to URI when encoding, URI to IRI when decoding",
+		"whatwg-c0\tWHATWG C0 control percent-encode set, e.g opaque paths",
+		"whatwg-fragment\tWHATWG fragment percent-encode set",
+		"whatwg-query\tWHATWG query percent-encode set, for non-special schemes",
+		"whatwg-special-query\tWHATWG special-query percent-encode set, for http(s), ws(s), ftp, and file",
+		"whatwg-path\tWHATWG path percent-encode set",
+		"whatwg-userinfo\tWHATWG userinfo percent-encode set",
+		"whatwg-component\tWHATWG component percent-encode set, like encodeURIComponent",
+		"whatwg-form\tWHATWG application/x-www-form-urlencoded percent-encode set",
+		"js-encodeuri\tJavaScript encodeURI()",
+		"js-encodeuricomponent\tJavaScript encodeURIComponent()",
+		"js-escape\tJavaScript escape(), using %uXXXX for non-Latin-1",
+		"php-urlencode\tPHP urlencode(), space as +",
+		"php-rawurlencode\tPHP rawurlencode()",
+		"python-quote\tPython urllib.parse.quote()",
+		"python-quote-plus\tPython urllib.parse.quote_plus(), space as +",
+		"java-urlencoder\tJava java.net.URLEncoder.encode(), space as +",
+		"dotnet-escapedatastring\t.NET System.Uri.EscapeDataString()",
 	}, cobra.ShellCompDirectiveNoFileComp
 }
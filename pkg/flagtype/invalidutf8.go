@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package flagtype
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jilleJr/urlencode/pkg/codec"
+	"github.com/spf13/cobra"
+)
+
+// InvalidUTF8 is a codec.InvalidUTF8Policy that can be set from a flag.
+type InvalidUTF8 codec.InvalidUTF8Policy
+
+const (
+	InvalidUTF8Error       = InvalidUTF8(codec.InvalidUTF8Fail)
+	InvalidUTF8Replace     = InvalidUTF8(codec.InvalidUTF8Replace)
+	InvalidUTF8KeepEscaped = InvalidUTF8(codec.InvalidUTF8KeepEscaped)
+)
+
+// String is used both by fmt.Print and by Cobra in help text
+func (p *InvalidUTF8) String() string {
+	return string(*p)
+}
+
+// Set must have pointer receiver so it doesn't change the value of a copy
+func (p *InvalidUTF8) Set(v string) error {
+	switch strings.ToLower(v) {
+	case "error":
+		*p = InvalidUTF8Error
+	case "replace":
+		*p = InvalidUTF8Replace
+	case "keep-escaped":
+		*p = InvalidUTF8KeepEscaped
+	default:
+		return fmt.Errorf(`invalid policy: %q, must be one of "error", "replace", or "keep-escaped"`, v)
+	}
+	return nil
+}
+
+// Type is only used in help text
+func (p *InvalidUTF8) Type() string {
+	return "policy"
+}
+
+func CompleteInvalidUTF8(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{
+		"error\tFail on invalid UTF-8",
+		"replace\tReplace invalid bytes with U+FFFD",
+		"keep-escaped\tKeep invalid bytes percent-encoded",
+	}, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package flagtype
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type OutputFormat string
+
+const (
+	OutputFormatText  OutputFormat = "text"
+	OutputFormatJSONL OutputFormat = "jsonl"
+)
+
+// String is used both by fmt.Print and by Cobra in help text
+func (f *OutputFormat) String() string {
+	return string(*f)
+}
+
+// Set must have pointer receiver so it doesn't change the value of a copy
+func (f *OutputFormat) Set(v string) error {
+	switch strings.ToLower(v) {
+	case "text":
+		*f = OutputFormatText
+	case "jsonl", "ndjson":
+		*f = OutputFormatJSONL
+	default:
+		return fmt.Errorf(`invalid output format: %q, must be one of "text" or "jsonl"`, v)
+	}
+	return nil
+}
+
+// Type is only used in help text
+func (f *OutputFormat) Type() string {
+	return "format"
+}
+
+func CompleteOutputFormat(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{
+		"text\tThe encoded/decoded values, with colors",
+		"jsonl\tOne JSON object per value, with the input, output, and changes",
+	}, cobra.ShellCompDirectiveNoFileComp
+}
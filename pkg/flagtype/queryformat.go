@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package flagtype
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type QueryFormat string
+
+const (
+	QueryFormatTable QueryFormat = "table"
+	QueryFormatJSON  QueryFormat = "json"
+	QueryFormatLines QueryFormat = "lines"
+)
+
+// String is used both by fmt.Print and by Cobra in help text
+func (f *QueryFormat) String() string {
+	return string(*f)
+}
+
+// Set must have pointer receiver so it doesn't change the value of a copy
+func (f *QueryFormat) Set(v string) error {
+	switch strings.ToLower(v) {
+	case "table":
+		*f = QueryFormatTable
+	case "json":
+		*f = QueryFormatJSON
+	case "lines":
+		*f = QueryFormatLines
+	default:
+		return fmt.Errorf(`invalid format: %q, must be one of "table", "json", or "lines"`, v)
+	}
+	return nil
+}
+
+// Type is only used in help text
+func (f *QueryFormat) Type() string {
+	return "format"
+}
+
+func CompleteQueryFormat(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{
+		"table\tAligned key and value columns",
+		"json\tJSON array of key and value objects",
+		"lines\tOne key=value per line",
+	}, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package flagtype
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jilleJr/urlencode/pkg/codec"
+	"github.com/spf13/cobra"
+)
+
+// Space is a codec.Space that can be set from a flag.
+type Space codec.Space
+
+const (
+	SpacePlus    = Space(codec.SpacePlus)
+	SpacePercent = Space(codec.SpacePercent)
+)
+
+// String is used both by fmt.Print and by Cobra in help text
+func (s *Space) String() string {
+	return string(*s)
+}
+
+// Set must have pointer receiver so it doesn't change the value of a copy
+func (s *Space) Set(v string) error {
+	switch strings.ToLower(v) {
+	case "plus", "+":
+		*s = SpacePlus
+	case "percent", "%20":
+		*s = SpacePercent
+	default:
+		return fmt.Errorf(`invalid space: %q, must be one of "plus" or "percent"`, v)
+	}
+	return nil
+}
+
+// Type is only used in help text
+func (s *Space) Type() string {
+	return "space"
+}
+
+func CompleteSpace(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{
+		"plus\tEscape space as +",
+		"percent\tEscape space as %20",
+	}, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,222 @@
+// SPDX-FileCopyrightText: 2021 Kalle Fagerberg
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package punycode implements the Punycode encoding of Unicode strings into
+// ASCII, as used for internationalized domain names. See
+// https://www.rfc-editor.org/rfc/rfc3492.
+package punycode
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// Bootstring parameters for Punycode, from RFC 3492 section 5.
+const (
+	base        = 36
+	tMin        = 1
+	tMax        = 26
+	skew        = 38
+	damp        = 700
+	initialBias = 72
+	initialN    = 128
+	delimiter   = '-'
+)
+
+var (
+	// ErrInvalidUTF8 is returned by Encode when the input is not valid UTF-8.
+	ErrInvalidUTF8 = errors.New("punycode: invalid UTF-8")
+	// ErrInvalidDigit is returned by Decode for bytes that are not Punycode
+	// digits, or non-ASCII bytes before the last delimiter.
+	ErrInvalidDigit = errors.New("punycode: invalid digit")
+	// ErrIncomplete is returned by Decode when the input ends in the middle
+	// of an encoded code point.
+	ErrIncomplete = errors.New("punycode: incomplete input")
+	// ErrOverflow is returned when the input encodes a code point or delta
+	// that is out of range.
+	ErrOverflow = errors.New("punycode: overflow")
+)
+
+// Encode returns the Punycode encoding of s, without any "xn--" prefix.
+func Encode(s string) (string, error) {
+	if !utf8.ValidString(s) {
+		return "", ErrInvalidUTF8
+	}
+	runes := []rune(s)
+	var sb strings.Builder
+	for _, r := range runes {
+		if r < initialN {
+			sb.WriteRune(r)
+		}
+	}
+	basic := sb.Len()
+	handled := basic
+	if basic > 0 {
+		sb.WriteByte(delimiter)
+	}
+
+	n := rune(initialN)
+	delta, bias := 0, initialBias
+	for handled < len(runes) {
+		// Find the smallest code point not yet handled.
+		m := rune(math.MaxInt32)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		if int(m-n) > (math.MaxInt32-delta)/(handled+1) {
+			return "", ErrOverflow
+		}
+		delta += int(m-n) * (handled + 1)
+		n = m
+		for _, r := range runes {
+			if r < n {
+				delta++
+				if delta > math.MaxInt32 {
+					return "", ErrOverflow
+				}
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := base; ; k += base {
+				t := threshold(k, bias)
+				if q < t {
+					break
+				}
+				sb.WriteByte(encodeDigit(t + (q-t)%(base-t)))
+				q = (q - t) / (base - t)
+			}
+			sb.WriteByte(encodeDigit(q))
+			bias = adapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return sb.String(), nil
+}
+
+// Decode returns the Unicode string encoded by the Punycode s, without any
+// "xn--" prefix. Both uppercase and lowercase digits are accepted.
+func Decode(s string) (string, error) {
+	var output []rune
+	pos := 0
+	if i := strings.LastIndexByte(s, delimiter); i >= 0 {
+		for j := 0; j < i; j++ {
+			if s[j] >= utf8.RuneSelf {
+				return "", ErrInvalidDigit
+			}
+			output = append(output, rune(s[j]))
+		}
+		pos = i + 1
+	}
+
+	n := rune(initialN)
+	i, bias := 0, initialBias
+	for pos < len(s) {
+		oldi, w := i, 1
+		for k := base; ; k += base {
+			if pos >= len(s) {
+				return "", ErrIncomplete
+			}
+			digit, ok := decodeDigit(s[pos])
+			pos++
+			if !ok {
+				return "", ErrInvalidDigit
+			}
+			if digit > (math.MaxInt32-i)/w {
+				return "", ErrOverflow
+			}
+			i += digit * w
+			t := threshold(k, bias)
+			if digit < t {
+				break
+			}
+			if w > math.MaxInt32/(base-t) {
+				return "", ErrOverflow
+			}
+			w *= base - t
+		}
+		length := len(output) + 1
+		bias = adapt(i-oldi, length, oldi == 0)
+		if i/length > utf8.MaxRune-int(n) {
+			return "", ErrOverflow
+		}
+		n += rune(i / length)
+		i %= length
+		if !utf8.ValidRune(n) {
+			return "", ErrOverflow
+		}
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = n
+		i++
+	}
+	return string(output), nil
+}
+
+// threshold returns the t(k) of RFC 3492 section 6.2 and 6.3.
+func threshold(k, bias int) int {
+	switch {
+	case k <= bias:
+		return tMin
+	case k >= bias+tMax:
+		return tMax
+	}
+	return k - bias
+}
+
+// adapt is the bias adaptation function of RFC 3492 section 6.1.
+func adapt(delta, numPoints int, firstTime bool) int {
+	if firstTime {
+		delta /= damp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((base-tMin)*tMax)/2 {
+		delta /= base - tMin
+		k += base
+	}
+	return k + (base-tMin+1)*delta/(delta+skew)
+}
+
+func encodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func decodeDigit(c byte) (int, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c-'0') + 26, true
+	case 'a' <= c && c <= 'z':
+		return int(c - 'a'), true
+	case 'A' <= c && c <= 'Z':
+		return int(c - 'A'), true
+	}
+	return 0, false
+}